@@ -0,0 +1,347 @@
+package builder
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DiagnosticKind is the kind of a Diagnostic.
+type DiagnosticKind int
+
+const (
+	// Unsatisfiable means the conditions can't be matched by any document.
+	Unsatisfiable DiagnosticKind = iota
+	// Tautology means the conditions are matched by all documents.
+	Tautology
+)
+
+func (k DiagnosticKind) String() string {
+	if k == Tautology {
+		return "tautology"
+	}
+	return "unsatisfiable"
+}
+
+// Diagnostic reports conditions which are unsatisfiable or always true, see Builder.Analyze.
+type Diagnostic struct {
+	Kind DiagnosticKind
+	// Field is the path of the field, nested fields of `$elemMatch` are joined with dots.
+	// It's empty for a logical operator.
+	Field string
+	// Ops are the operators involved.
+	Ops     []string
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s {%s}: %s", d.Kind, d.Field, strings.Join(d.Ops, ", "), d.Message)
+}
+
+// Analyze reports unsatisfiable conditions and tautologies of the builder:
+//
+//   - empty ranges, eg: `$gte: 10, $lte: 5`.
+//   - `$eq` out of the range or excluded by `$ne` and `$nin`.
+//   - empty `$in` and `$all`, or `$in` whose values are all excluded by `$nin`.
+//   - `$exists: false` with a condition which can't be matched by missing fields.
+//   - empty `$nin`, and `$or` with an empty branch or complementary branches such as `$eq` and `$ne` of the same value.
+//
+// Conditions of a field are analyzed as if the field is not an array,
+// since different conditions can be matched by different elements of an array, eg: [3, 12] matches `$gte: 10, $lte: 5`.
+func (b *Builder) Analyze() []Diagnostic {
+	a := &analyzer{}
+	a.node(b.AST(), "")
+	return a.diags
+}
+
+type analyzer struct {
+	diags []Diagnostic
+}
+
+func (a *analyzer) report(kind DiagnosticKind, field, msg string, ops ...string) {
+	a.diags = append(a.diags, Diagnostic{Kind: kind, Field: field, Ops: ops, Message: msg})
+}
+
+// node analyzes n, fields are prefixed with prefix in `$elemMatch`.
+func (a *analyzer) node(n Node, prefix string) {
+	switch n := n.(type) {
+	case *And:
+		conds := flattenAnd(n)
+		a.fields(conds, prefix)
+		for _, c := range conds {
+			a.node(c, prefix)
+		}
+	case *Or:
+		a.or(n, prefix)
+		for _, c := range n.Children {
+			a.node(asAnd(c), prefix)
+		}
+	case *Nor:
+		for _, c := range n.Children {
+			a.node(asAnd(c), prefix)
+		}
+	case *ElemMatch:
+		a.node(asAnd(n.Filter), joinPath(prefix, n.Field))
+	}
+}
+
+// flattenAnd returns the conditions of n and its nested And.
+func flattenAnd(n *And) []Node {
+	res := []Node{}
+	for _, c := range n.Children {
+		if and, ok := c.(*And); ok {
+			res = append(res, flattenAnd(and)...)
+			continue
+		}
+		res = append(res, c)
+	}
+	return res
+}
+
+// joinPath joins a field to the path of `$elemMatch`.
+func joinPath(prefix, field string) string {
+	switch {
+	case prefix == "":
+		return field
+	case field == "":
+		return prefix
+	}
+	return prefix + "." + field
+}
+
+// fields analyzes the conditions of each field in nodes which are ANDed.
+func (a *analyzer) fields(nodes []Node, prefix string) {
+	order := []string{}
+	byField := map[string][]*Compare{}
+	for _, n := range nodes {
+		c, ok := n.(*Compare)
+		if !ok {
+			continue
+		}
+		if _, seen := byField[c.Field]; !seen {
+			order = append(order, c.Field)
+		}
+		byField[c.Field] = append(byField[c.Field], c)
+	}
+	for _, f := range order {
+		a.field(joinPath(prefix, f), byField[f])
+	}
+}
+
+// field analyzes the conditions of a single field.
+func (a *analyzer) field(field string, conds []*Compare) {
+	var lowers, uppers, eqs, nes, nins, ins []*Compare
+	var notExists *Compare
+	for _, c := range conds {
+		switch c.Op {
+		case _gt, _gte:
+			lowers = append(lowers, c)
+		case _lt, _lte:
+			uppers = append(uppers, c)
+		case _eq, "":
+			if isScalar(c.Value) {
+				eqs = append(eqs, c)
+			}
+		case _ne:
+			nes = append(nes, c)
+		case _in:
+			ins = append(ins, c)
+		case _nin:
+			nins = append(nins, c)
+			if vals, ok := sliceValues(c.Value); ok && len(vals) == 0 {
+				a.report(Tautology, field, "$nin is empty", _nin)
+			}
+		case _all:
+			if vals, ok := sliceValues(c.Value); ok && len(vals) == 0 {
+				a.report(Unsatisfiable, field, "$all is empty", _all)
+			}
+		case _exists:
+			if exists, ok := c.Value.(bool); ok && !exists {
+				notExists = c
+			}
+		}
+	}
+
+	for _, l := range lowers {
+		for _, u := range uppers {
+			if !sameTypeBracket(l.Value, u.Value) {
+				continue
+			}
+			if cmp := CompareValues(l.Value, u.Value); cmp > 0 || cmp == 0 && (l.Op == _gt || u.Op == _lt) {
+				a.report(Unsatisfiable, field, fmt.Sprintf("range is empty, %s: %v and %s: %v", l.Op, l.Value, u.Op, u.Value), l.Op, u.Op)
+			}
+		}
+	}
+
+	for _, eq := range eqs {
+		for _, bound := range append(append([]*Compare{}, lowers...), uppers...) {
+			if !sameTypeBracket(eq.Value, bound.Value) {
+				continue
+			}
+			if !inBound(eq.Value, bound) {
+				a.report(Unsatisfiable, field, fmt.Sprintf("%v is out of %s: %v", eq.Value, bound.Op, bound.Value), opName(eq), bound.Op)
+			}
+		}
+		for _, ne := range nes {
+			if valueIn(eq.Value, []interface{}{ne.Value}) {
+				a.report(Unsatisfiable, field, fmt.Sprintf("%v is excluded by $ne", eq.Value), opName(eq), _ne)
+			}
+		}
+		for _, nin := range nins {
+			if vals, ok := sliceValues(nin.Value); ok && valueIn(eq.Value, vals) {
+				a.report(Unsatisfiable, field, fmt.Sprintf("%v is excluded by $nin", eq.Value), opName(eq), _nin)
+			}
+		}
+	}
+
+	for _, in := range ins {
+		vals, ok := sliceValues(in.Value)
+		if !ok {
+			continue
+		}
+		if len(vals) == 0 {
+			a.report(Unsatisfiable, field, "$in is empty", _in)
+			continue
+		}
+		for _, nin := range nins {
+			excluded, ok := sliceValues(nin.Value)
+			if ok && allValuesIn(vals, excluded) {
+				a.report(Unsatisfiable, field, "all values of $in are excluded by $nin", _in, _nin)
+			}
+		}
+	}
+
+	if notExists != nil {
+		for _, c := range conds {
+			if !matchesMissing(c) {
+				a.report(Unsatisfiable, field, fmt.Sprintf("missing field can't match %s", opName(c)), _exists, opName(c))
+			}
+		}
+	}
+}
+
+// or reports `$or` which is always true.
+func (a *analyzer) or(n *Or, prefix string) {
+	for _, br := range n.Children {
+		if len(andChildren(br)) == 0 {
+			a.report(Tautology, prefix, "$or has an empty branch", _or)
+			return
+		}
+	}
+
+	for i, x := range n.Children {
+		cx, ok := singleCompare(x)
+		if !ok {
+			continue
+		}
+		for _, y := range n.Children[i+1:] {
+			cy, ok := singleCompare(y)
+			if ok && cx.Field == cy.Field && complementary(cx, cy) {
+				a.report(Tautology, joinPath(prefix, cx.Field), "$or of complementary conditions", _or, opName(cx), opName(cy))
+			}
+		}
+	}
+}
+
+// singleCompare returns the Compare if n only contains a single Compare.
+func singleCompare(n Node) (*Compare, bool) {
+	children := andChildren(n)
+	if len(children) != 1 {
+		return nil, false
+	}
+	c, ok := children[0].(*Compare)
+	return c, ok
+}
+
+// complementaryOps are operators whose conditions are negations of each other with the same value.
+var complementaryOps = map[string]string{_eq: _ne, _ne: _eq, _in: _nin, _nin: _in}
+
+// complementary reports whether x is the negation of y.
+func complementary(x, y *Compare) bool {
+	if x.Op == _exists && y.Op == _exists {
+		ex, okx := x.Value.(bool)
+		ey, oky := y.Value.(bool)
+		return okx && oky && ex != ey
+	}
+	if complementaryOps[opName(x)] != opName(y) {
+		return false
+	}
+	if x.Op == _in || x.Op == _nin {
+		xs, okx := sliceValues(x.Value)
+		ys, oky := sliceValues(y.Value)
+		return okx && oky && allValuesIn(xs, ys) && allValuesIn(ys, xs)
+	}
+	return isScalar(x.Value) && isScalar(y.Value) && valueIn(x.Value, []interface{}{y.Value})
+}
+
+// opName returns the operator of c, a literal value is treated as `$eq`.
+func opName(c *Compare) string {
+	if c.Op == "" {
+		return _eq
+	}
+	return c.Op
+}
+
+// isScalar reports whether v is compared as a single value, arrays and regex literals are not.
+func isScalar(v interface{}) bool {
+	if isRegex(v) {
+		return false
+	}
+	_, isSlice := sliceValues(v)
+	return !isSlice
+}
+
+// inBound reports whether v satisfies the range bound.
+func inBound(v interface{}, bound *Compare) bool {
+	cmp := CompareValues(v, bound.Value)
+	switch bound.Op {
+	case _gt:
+		return cmp > 0
+	case _gte:
+		return cmp >= 0
+	case _lt:
+		return cmp < 0
+	}
+	return cmp <= 0
+}
+
+// valueIn reports whether v equals to any of vals.
+func valueIn(v interface{}, vals []interface{}) bool {
+	for _, u := range vals {
+		if sameTypeBracket(u, v) && CompareValues(u, v) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// allValuesIn reports whether all of vals are in set.
+func allValuesIn(vals, set []interface{}) bool {
+	for _, v := range vals {
+		if !valueIn(v, set) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesMissing reports whether the condition c can be matched by a missing field.
+func matchesMissing(c *Compare) bool {
+	switch c.Op {
+	case _eq, "", _gte, _lte:
+		return c.Value == nil
+	case _ne:
+		return c.Value != nil
+	case _in:
+		vals, _ := sliceValues(c.Value)
+		return valueIn(nil, vals)
+	case _nin:
+		vals, _ := sliceValues(c.Value)
+		return !valueIn(nil, vals)
+	case _exists:
+		exists, _ := c.Value.(bool)
+		return !exists
+	case _not:
+		return true
+	}
+	return false
+}
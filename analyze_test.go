@@ -0,0 +1,60 @@
+package builder_test
+
+import (
+	"testing"
+
+	builder "github.com/JsyTech/mongo-filter-builder"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestBuilder_Analyze(t *testing.T) {
+	diags := builder.New().Num("age").Between(10, 5).Analyze()
+	assert.Equal(t, []builder.Diagnostic{{
+		Kind:    builder.Unsatisfiable,
+		Field:   "age",
+		Ops:     []string{"$gte", "$lte"},
+		Message: "range is empty, $gte: 10 and $lte: 5",
+	}}, diags)
+	assert.Equal(t, "unsatisfiable: age {$gte, $lte}: range is empty, $gte: 10 and $lte: 5", diags[0].String())
+
+	assert.Empty(t, builder.New().Num("age").Between(5, 10).Str("name").Eq("a").Analyze())
+	assert.Empty(t, builder.New().Num("age").Gte(5).Num("age").Lte(5).Analyze())
+	assert.Len(t, builder.New().Num("age").Gt(5).Num("age").Lte(5).Analyze(), 1)
+	// values of different types are not compared
+	assert.Empty(t, builder.New().Any("age").Gte(10).Any("age").Lte("a").Analyze())
+
+	cases := []struct {
+		name   string
+		filter bson.M
+		kind   builder.DiagnosticKind
+		field  string
+		ops    []string
+	}{
+		{"eq out of range", bson.M{"age": bson.M{"$eq": 3, "$gt": 5}}, builder.Unsatisfiable, "age", []string{"$eq", "$gt"}},
+		{"eq excluded by ne", bson.M{"age": bson.M{"$eq": 3, "$ne": 3.0}}, builder.Unsatisfiable, "age", []string{"$eq", "$ne"}},
+		{"eq excluded by nin", bson.M{"age": bson.M{"$eq": 3, "$nin": bson.A{1, 3}}}, builder.Unsatisfiable, "age", []string{"$eq", "$nin"}},
+		{"empty in", bson.M{"tags": bson.M{"$in": bson.A{}}}, builder.Unsatisfiable, "tags", []string{"$in"}},
+		{"empty all", bson.M{"tags": bson.M{"$all": bson.A{}}}, builder.Unsatisfiable, "tags", []string{"$all"}},
+		{"in excluded by nin", bson.M{"tags": bson.M{"$in": bson.A{"a"}, "$nin": bson.A{"a", "b"}}}, builder.Unsatisfiable, "tags", []string{"$in", "$nin"}},
+		{"not exists", bson.M{"name": bson.M{"$exists": false, "$regex": "^a"}}, builder.Unsatisfiable, "name", []string{"$exists", "$regex"}},
+		{"elemMatch", bson.M{"items": bson.M{"$elemMatch": bson.M{"qty": bson.M{"$gt": 5, "$lt": 1}}}}, builder.Unsatisfiable, "items.qty", []string{"$gt", "$lt"}},
+		{"empty nin", bson.M{"tags": bson.M{"$nin": bson.A{}}}, builder.Tautology, "tags", []string{"$nin"}},
+		{"empty branch", bson.M{"$or": bson.A{bson.M{}, bson.M{"a": 1}}}, builder.Tautology, "", []string{"$or"}},
+		{"complementary", bson.M{"status": bson.M{"$eq": "a"}, "$or": bson.A{bson.M{"a": 1}, bson.M{"a": bson.M{"$ne": 1}}}}, builder.Tautology, "a", []string{"$or", "$eq", "$ne"}},
+	}
+	for _, c := range cases {
+		b, err := builder.FromBSON(c.filter)
+		assert.Nil(t, err, c.name)
+		diags := b.Analyze()
+		if assert.Len(t, diags, 1, c.name) {
+			assert.Equal(t, c.kind, diags[0].Kind, c.name)
+			assert.Equal(t, c.field, diags[0].Field, c.name)
+			assert.Equal(t, c.ops, diags[0].Ops, c.name)
+		}
+	}
+
+	// missing fields can match these conditions
+	b, _ := builder.FromBSON(bson.M{"name": bson.M{"$exists": false, "$ne": "a", "$nin": bson.A{"b"}, "$eq": nil}})
+	assert.Empty(t, b.Analyze())
+}
@@ -0,0 +1,77 @@
+package builder
+
+// arrCond represents an array-type condition builder.
+type arrCond struct {
+	*cond
+}
+
+// newArrCond constructs a new arrCond.
+func newArrCond(key string, builderRef *Builder) *arrCond {
+	return &arrCond{
+		cond: newCond(key, builderRef),
+	}
+}
+
+// Negate makes the following operators to be wrapped into `$not`, eg: Arr("tags").Negate().Size(0).
+func (c *arrCond) Negate() *arrCond {
+	c.cond.Negate()
+	return c
+}
+
+// All adds `$all: vals` to the builder
+func (c *arrCond) All(vals ...interface{}) *Builder {
+	return c.cond.set(_all, vals)
+}
+
+// ContainsAll calls c.All(vals...) under the wood
+func (c *arrCond) ContainsAll(vals ...interface{}) *Builder {
+	return c.All(vals...)
+}
+
+// ContainsAny adds `$in: vals` to the builder
+func (c *arrCond) ContainsAny(vals ...interface{}) *Builder {
+	return c.cond.In(vals)
+}
+
+// Size adds `$size: n` to the builder
+func (c *arrCond) Size(n int) *Builder {
+	return c.cond.set(_size, n)
+}
+
+// Empty adds `$size: 0` to the builder
+func (c *arrCond) Empty() *Builder {
+	return c.Size(0)
+}
+
+// NotEmpty adds `key.0: {$exists: true}` to the builder,
+// which matches arrays with at least one element.
+func (c *arrCond) NotEmpty() *Builder {
+	first := newCond(c.key+".0", c.builder)
+	first.negate = c.negate
+	return first.Exists(true)
+}
+
+// ElemMatch adds `$elemMatch: {...}` to the builder, the element predicate is built by fn.
+//
+// For arrays of scalar values, use an empty key in fn, eg:
+//
+//	b.Arr("scores").ElemMatch(func(e *Builder) {
+//		e.Num("").Between(80, 85)
+//	})
+//
+// If fn fails any condition in non-strict mode, the whole `$elemMatch` is skipped,
+// as the rest of the predicate would match more elements than expected.
+func (c *arrCond) ElemMatch(fn func(*Builder)) *Builder {
+	sub := c.builder.sub(fn)
+	if len(sub.errs) != 0 {
+		return c.builder
+	}
+	n := &ElemMatch{Field: c.key, Filter: sub.AST()}
+	if _, ok := c.builder.checkText(c.key, _elemMatch, n, nil); !ok {
+		return c.builder
+	}
+	if c.negate {
+		return c.cond.set(_elemMatch, renderOpValue(n))
+	}
+	return c.builder.put(n)
+}
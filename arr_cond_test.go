@@ -0,0 +1,43 @@
+package builder_test
+
+import (
+	"testing"
+
+	builder "github.com/JsyTech/mongo-filter-builder"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestArrCond(t *testing.T) {
+	b := builder.New().Arr("tags").All("a", "b").Build()
+	c := bson.M{"tags": bson.M{"$all": []interface{}{"a", "b"}}}
+	assert.Equal(t, c, b)
+
+	b = builder.New().Arr("tags").ContainsAny("a", "b").Arr("tags").Size(3).Build()
+	c = bson.M{"tags": bson.M{"$in": []interface{}{"a", "b"}, "$size": 3}}
+	assert.Equal(t, c, b)
+
+	b = builder.New().Arr("items").Empty().Build()
+	c = bson.M{"items": bson.M{"$size": 0}}
+	assert.Equal(t, c, b)
+
+	b = builder.New().Arr("items").NotEmpty().Build()
+	c = bson.M{"items.0": bson.M{"$exists": true}}
+	assert.Equal(t, c, b)
+
+	b = builder.New().Arr("items").ElemMatch(func(e *builder.Builder) {
+		e.Str("sku").Eq("x-1").Num("qty").Gte(2)
+	}).Build()
+	c = bson.M{"items": bson.M{"$elemMatch": bson.M{
+		"sku": bson.M{"$eq": "x-1"},
+		"qty": bson.M{"$gte": 2},
+	}}}
+	assert.Equal(t, c, b)
+
+	// scalar elements
+	b = builder.New().Arr("scores").ElemMatch(func(e *builder.Builder) {
+		e.Num("").Between(80, 85)
+	}).Build()
+	c = bson.M{"scores": bson.M{"$elemMatch": bson.M{"$gte": 80, "$lte": 85}}}
+	assert.Equal(t, c, b)
+}
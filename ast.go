@@ -0,0 +1,218 @@
+package builder
+
+import "fmt"
+
+// Node is a node of the filter expression tree built by Builder, see Builder.AST.
+//
+// Nodes are immutable once they are added to a tree,
+// use Rewrite to derive a new tree instead of modifying nodes in place.
+type Node interface {
+	node()
+}
+
+// And matches documents matching all of its children.
+// Field conditions in the same And are rendered into the same document, eg: {a: {$gt: 1}, b: {$eq: 2}}.
+type And struct {
+	Children []Node
+}
+
+// Or matches documents matching any of its children, eg: {$or: [...]}.
+type Or struct {
+	Children []Node
+}
+
+// Nor matches documents matching none of its children, eg: {$nor: [...]}.
+type Nor struct {
+	Children []Node
+}
+
+// Not negates operators of a field, eg: {field: {$not: {$gt: 5}}}.
+type Not struct {
+	Field string
+	// Ops are the negated operators, their Field should be the same as Not.Field.
+	Ops []*Compare
+}
+
+// Compare is an operator of a field, eg: {field: {$gt: 5}}.
+// If Op is empty, the Value is rendered as a literal value, eg: {field: 5}.
+type Compare struct {
+	Field string
+	Op    string
+	Value interface{}
+}
+
+// ElemMatch matches array elements with Filter, eg: {field: {$elemMatch: {...}}}.
+// Conditions with an empty field in Filter are applied to the elements themselves.
+type ElemMatch struct {
+	Field  string
+	Filter Node
+}
+
+// Expr is an aggregation expression, eg: {$expr: {...}}.
+type Expr struct {
+	Value interface{}
+}
+
+// Text is a full-text search, eg: {$text: {$search: "..."}}.
+type Text struct {
+	Search             string
+	Language           string
+	CaseSensitive      *bool
+	DiacriticSensitive *bool
+}
+
+// Raw is a top level operator which isn't modeled by other nodes, eg: {$jsonSchema: {...}}.
+type Raw struct {
+	Key   string
+	Value interface{}
+}
+
+func (*And) node()       {}
+func (*Or) node()        {}
+func (*Nor) node()       {}
+func (*Not) node()       {}
+func (*Compare) node()   {}
+func (*ElemMatch) node() {}
+func (*Expr) node()      {}
+func (*Text) node()      {}
+func (*Raw) node()       {}
+
+// Visitor visits nodes of a tree, see Walk.
+type Visitor interface {
+	// Visit is called for each node,
+	// children of the node are visited with the returned visitor w if it's not nil.
+	Visit(n Node) (w Visitor)
+}
+
+// VisitorFunc adapts a function to Visitor, children are visited if f returns true.
+type VisitorFunc func(n Node) bool
+
+func (f VisitorFunc) Visit(n Node) Visitor {
+	if f(n) {
+		return f
+	}
+	return nil
+}
+
+// Walk traverses the tree of n in depth-first order like go/ast.Walk.
+func Walk(v Visitor, n Node) {
+	if v = v.Visit(n); v == nil {
+		return
+	}
+	switch n := n.(type) {
+	case *And:
+		walkList(v, n.Children)
+	case *Or:
+		walkList(v, n.Children)
+	case *Nor:
+		walkList(v, n.Children)
+	case *Not:
+		for _, op := range n.Ops {
+			Walk(v, op)
+		}
+	case *ElemMatch:
+		if n.Filter != nil {
+			Walk(v, n.Filter)
+		}
+	}
+}
+
+func walkList(v Visitor, nodes []Node) {
+	for _, n := range nodes {
+		Walk(v, n)
+	}
+}
+
+// Rewriter rewrites nodes of a tree, see Rewrite.
+type Rewriter interface {
+	// Rewrite returns the replacement of n, whose children have been rewritten already.
+	// Returning nil removes n from its parent.
+	Rewrite(n Node) Node
+}
+
+// RewriterFunc adapts a function to Rewriter.
+type RewriterFunc func(n Node) Node
+
+func (f RewriterFunc) Rewrite(n Node) Node {
+	return f(n)
+}
+
+// Rewrite rewrites the tree of n in post-order and returns the new tree.
+// The given tree is never modified, unchanged subtrees are shared by the new tree.
+//
+// Operators of Not can only be rewritten to *Compare or nil, otherwise a panic will occur.
+func Rewrite(n Node, r Rewriter) Node {
+	switch n := n.(type) {
+	case *And:
+		if children, changed := rewriteList(n.Children, r); changed {
+			return r.Rewrite(&And{Children: children})
+		}
+	case *Or:
+		if children, changed := rewriteList(n.Children, r); changed {
+			return r.Rewrite(&Or{Children: children})
+		}
+	case *Nor:
+		if children, changed := rewriteList(n.Children, r); changed {
+			return r.Rewrite(&Nor{Children: children})
+		}
+	case *Not:
+		ops := make([]*Compare, 0, len(n.Ops))
+		changed := false
+		for _, op := range n.Ops {
+			res := Rewrite(op, r)
+			if res != Node(op) {
+				changed = true
+			}
+			if res == nil {
+				continue
+			}
+			c, ok := res.(*Compare)
+			if !ok {
+				panic(fmt.Errorf("filterBuilder: operator of Not can't be rewritten to %T", res))
+			}
+			ops = append(ops, c)
+		}
+		if changed {
+			return r.Rewrite(&Not{Field: n.Field, Ops: ops})
+		}
+	case *ElemMatch:
+		if n.Filter != nil {
+			if f := Rewrite(n.Filter, r); f != n.Filter {
+				return r.Rewrite(&ElemMatch{Field: n.Field, Filter: f})
+			}
+		}
+	}
+	return r.Rewrite(n)
+}
+
+// rewriteList rewrites nodes and reports whether any of them is changed.
+func rewriteList(nodes []Node, r Rewriter) ([]Node, bool) {
+	// res is only allocated once a node is changed, so an unchanged list costs nothing
+	var res []Node
+	for i, n := range nodes {
+		rn := Rewrite(n, r)
+		if rn != n && res == nil {
+			res = append(make([]Node, 0, len(nodes)), nodes[:i]...)
+		}
+		if res != nil && rn != nil {
+			res = append(res, rn)
+		}
+	}
+	if res == nil {
+		return nodes, false
+	}
+	return res, true
+}
+
+// fieldOp returns the field and operator of a field-level node.
+func fieldOp(n Node) (field, op string, ok bool) {
+	switch n := n.(type) {
+	case *Compare:
+		return n.Field, n.Op, true
+	case *Not:
+		return n.Field, _not, true
+	case *ElemMatch:
+		return n.Field, _elemMatch, true
+	}
+	return "", "", false
+}
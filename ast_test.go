@@ -0,0 +1,130 @@
+package builder_test
+
+import (
+	"testing"
+
+	builder "github.com/JsyTech/mongo-filter-builder"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestBuilder_AST(t *testing.T) {
+	b := builder.New().
+		Str("name").Eq("a").
+		Num("age").Negate().Gt(18).
+		Or().
+		Str("name").Eq("b")
+	c := &builder.Or{Children: []builder.Node{
+		&builder.And{Children: []builder.Node{
+			&builder.Compare{Field: "name", Op: "$eq", Value: "a"},
+			&builder.Not{Field: "age", Ops: []*builder.Compare{{Field: "age", Op: "$gt", Value: 18}}},
+		}},
+		&builder.And{Children: []builder.Node{
+			&builder.Compare{Field: "name", Op: "$eq", Value: "b"},
+		}},
+	}}
+	assert.Equal(t, c, b.AST())
+
+	// the same operator of a field is replaced in place
+	ast := builder.New().Num("age").Gt(1).Str("name").Eq("a").Num("age").Gt(2).AST()
+	assert.Equal(t, &builder.And{Children: []builder.Node{
+		&builder.Compare{Field: "age", Op: "$gt", Value: 2},
+		&builder.Compare{Field: "name", Op: "$eq", Value: "a"},
+	}}, ast)
+
+	assert.Equal(t, &builder.And{}, builder.New().AST())
+}
+
+func TestFromAST(t *testing.T) {
+	ast := &builder.And{Children: []builder.Node{
+		&builder.Compare{Field: "name", Op: "$eq", Value: "a"},
+		&builder.Text{Search: "coffee"},
+		&builder.ElemMatch{Field: "scores", Filter: &builder.And{Children: []builder.Node{
+			&builder.Compare{Op: "$gte", Value: 80},
+		}}},
+	}}
+	b := builder.FromAST(ast).Num("age").Lt(30).BuildD()
+	c := bson.D{
+		{Key: "name", Value: bson.D{{Key: "$eq", Value: "a"}}},
+		{Key: "$text", Value: bson.D{{Key: "$search", Value: "coffee"}}},
+		{Key: "scores", Value: bson.D{{Key: "$elemMatch", Value: bson.D{{Key: "$gte", Value: 80}}}}},
+		{Key: "age", Value: bson.D{{Key: "$lt", Value: 30}}},
+	}
+	assert.Equal(t, c, b)
+	// the given tree is not modified
+	assert.Len(t, ast.Children, 3)
+
+	// branches of a root Or are separated by Or()
+	or := builder.New().Str("name").Eq("a").Or().Str("name").Eq("b").AST()
+	m := builder.FromAST(or).Str("age").Eq("c").Build()
+	assert.Equal(t, bson.M{"$or": []bson.M{
+		{"name": bson.M{"$eq": "a"}},
+		{"name": bson.M{"$eq": "b"}, "age": bson.M{"$eq": "c"}},
+	}}, m)
+}
+
+func TestWalk(t *testing.T) {
+	b := builder.New().
+		Str("name").Eq("a").
+		OrGroup(func(g *builder.Builder) {
+			g.Num("age").Lt(10).Or().Num("age").Gt(60)
+		})
+
+	fields := []string{}
+	builder.Walk(builder.VisitorFunc(func(n builder.Node) bool {
+		if c, ok := n.(*builder.Compare); ok {
+			fields = append(fields, c.Field+c.Op)
+		}
+		return true
+	}), b.AST())
+	assert.Equal(t, []string{"name$eq", "age$lt", "age$gt"}, fields)
+
+	// children are skipped if the visitor returns false
+	count := 0
+	builder.Walk(builder.VisitorFunc(func(n builder.Node) bool {
+		count++
+		_, isOr := n.(*builder.Or)
+		return !isOr
+	}), b.AST())
+	assert.Equal(t, 3, count)
+}
+
+func TestRewrite(t *testing.T) {
+	b := builder.New().Str("name").Eq("a").Num("age").Negate().Gt(18).Str("status").Eq("x")
+	before := b.Build()
+
+	// rename fields and drop status
+	ast := builder.Rewrite(b.AST(), builder.RewriterFunc(func(n builder.Node) builder.Node {
+		switch n := n.(type) {
+		case *builder.Compare:
+			if n.Field == "status" {
+				return nil
+			}
+			return &builder.Compare{Field: "user." + n.Field, Op: n.Op, Value: n.Value}
+		case *builder.Not:
+			return &builder.Not{Field: "user." + n.Field, Ops: n.Ops}
+		}
+		return n
+	}))
+	c := bson.M{
+		"user.name": bson.M{"$eq": "a"},
+		"user.age":  bson.M{"$not": bson.M{"$gt": 18}},
+	}
+	assert.Equal(t, c, builder.FromAST(ast).Build())
+	// the builder is not modified
+	assert.Equal(t, before, b.Build())
+
+	// unchanged trees are shared
+	root := b.AST()
+	same := builder.Rewrite(root, builder.RewriterFunc(func(n builder.Node) builder.Node { return n }))
+	assert.Same(t, root, same)
+
+	assert.Panics(t, func() {
+		builder.Rewrite(root, builder.RewriterFunc(func(n builder.Node) builder.Node {
+			if _, ok := n.(*builder.Compare); ok {
+				return &builder.Text{Search: "a"}
+			}
+			return n
+		}))
+	})
+}
@@ -0,0 +1,74 @@
+package builder
+
+import (
+	"reflect"
+	"sync"
+)
+
+// autoOp is the operator used by Auto for a field.
+type autoOp int
+
+const (
+	// autoEq adds `$eq` with the field value.
+	autoEq autoOp = iota
+	// autoIn adds `$in` with the field value if it's not empty.
+	autoIn
+	// autoDynamic resolves the operator from the field value with AutoWithKey, it's used by pointers and interfaces.
+	autoDynamic
+)
+
+// autoField is a cached field used by Auto.
+type autoField struct {
+	// index is the index sequence of the field, see reflect.Value.FieldByIndex.
+	index []int
+	// key is the resolved key of the field, see fieldKey.
+	key string
+	op  autoOp
+}
+
+// autoFieldCache caches []autoField by struct types, like the field cache of encoding/json.
+var autoFieldCache sync.Map // map[reflect.Type][]autoField
+
+// autoFields returns fields of the struct type t used by Auto, in the order of reflect.VisibleFields.
+// Unexported fields, fields ignored by bson tags, and fields of unsupported types are excluded.
+func autoFields(t reflect.Type) []autoField {
+	if fields, ok := autoFieldCache.Load(t); ok {
+		return fields.([]autoField)
+	}
+
+	fields := []autoField{}
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() {
+			continue
+		}
+		key, ok := fieldKey(f)
+		if !ok {
+			continue
+		}
+		op, ok := autoOpOf(f.Type)
+		if !ok {
+			continue
+		}
+		fields = append(fields, autoField{index: f.Index, key: key, op: op})
+	}
+	res, _ := autoFieldCache.LoadOrStore(t, fields)
+	return res.([]autoField)
+}
+
+// autoOpOf returns the operator of fields of type t, ok is false if the type is not supported by AutoWithKey.
+func autoOpOf(t reflect.Type) (op autoOp, ok bool) {
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return autoDynamic, true
+	case reflect.Array, reflect.Slice:
+		return autoIn, true
+	case
+		reflect.Bool,
+		reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return autoEq, true
+	}
+	return 0, false
+}
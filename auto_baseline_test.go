@@ -0,0 +1,43 @@
+package builder
+
+import "reflect"
+
+// AutoBaseline is a verbatim copy of Auto before fields were cached per struct type,
+// it's kept as the baseline of BenchmarkAuto.
+func AutoBaseline(b *Builder, queryStruct any) *Builder {
+	val := reflect.ValueOf(queryStruct)
+	for val.Kind() == reflect.Pointer {
+		if val.IsNil() {
+			return b
+		}
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		b.fail("", "Auto", errNotStruct)
+		return b
+	}
+
+	fields := reflect.VisibleFields(val.Type())
+	nonZeroFields := []reflect.StructField{}
+	for _, _f := range fields {
+		f := val.FieldByName(_f.Name)
+		if f.IsZero() {
+			continue
+		}
+		nonZeroFields = append(nonZeroFields, _f)
+	}
+
+	for _, _f := range nonZeroFields {
+
+		v := val.FieldByName(_f.Name)
+
+		key, ok := fieldKey(_f)
+		if !ok {
+			continue
+		}
+
+		b.AutoWithKey(key, v.Interface())
+	}
+
+	return b
+}
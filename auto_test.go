@@ -0,0 +1,127 @@
+package builder_test
+
+import (
+	"sync"
+	"testing"
+
+	builder "github.com/JsyTech/mongo-filter-builder"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type Base struct {
+	Tenant string
+	Tags   []string `bson:"labels"`
+}
+
+type Page struct {
+	Size int
+}
+
+type autoQuery struct {
+	Base
+	*Page
+	Name     string `bson:"name,omitempty"`
+	Age      *int
+	Any      interface{}
+	Status   status
+	internal string
+	Nested   struct{ A int }
+	Skipped  int `bson:"-"`
+}
+
+type status string
+
+func TestBuilder_Auto_Fields(t *testing.T) {
+	age := 0
+	q := autoQuery{
+		Base:     Base{Tenant: "t1", Tags: []string{"a"}},
+		Name:     "n",
+		Age:      &age,
+		Any:      1.5,
+		Status:   "on",
+		internal: "x",
+		Nested:   struct{ A int }{1},
+		Skipped:  1,
+	}
+	expected := bson.M{
+		"tenant": bson.M{"$eq": "t1"},
+		"labels": bson.M{"$in": []string{"a"}},
+		"name":   bson.M{"$eq": "n"},
+		"age":    bson.M{"$eq": 0},
+		"any":    bson.M{"$eq": 1.5},
+		"status": bson.M{"$eq": status("on")},
+	}
+	// the nil embedded *Page is skipped
+	assert.Equal(t, expected, builder.New().Auto(q).Build())
+	assert.Equal(t, expected, builder.New().Auto(&q).Build())
+
+	q.Page = &Page{Size: 10}
+	expected["size"] = bson.M{"$eq": 10}
+	assert.Equal(t, expected, builder.New().Auto(q).Build())
+}
+
+func TestBuilder_Auto_Concurrent(t *testing.T) {
+	type query struct {
+		ID   int
+		Name string
+	}
+	var wg sync.WaitGroup
+	for i := 1; i <= 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.Equal(t, bson.M{"id": bson.M{"$eq": i}, "name": bson.M{"$eq": "a"}},
+				builder.New().Auto(query{ID: i, Name: "a"}).Build())
+		}(i)
+	}
+	wg.Wait()
+}
+
+type benchQuery struct {
+	TenantID  string `bson:"tenant_id"`
+	UserName  string
+	Age       int
+	MinScore  float64
+	Tags      []string
+	Active    *bool
+	CreatedBy string
+	Ignored   string `bson:"-"`
+}
+
+var benchQueryValue = benchQuery{TenantID: "t1", UserName: "a", Age: 20, Tags: []string{"x"}, CreatedBy: "b"}
+
+func TestBuilder_AutoBaseline(t *testing.T) {
+	assert.Equal(t, builder.AutoBaseline(builder.New(), benchQueryValue).BuildD(), builder.New().Auto(benchQueryValue).BuildD())
+}
+
+// BenchmarkAuto and BenchmarkAuto_Baseline compare Auto with and without the field cache.
+// End to end, Build costs more than Auto itself, so the cache only saves a small part of
+// BenchmarkAuto_Build compared with BenchmarkAuto_BaselineBuild.
+func BenchmarkAuto(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		builder.New().Auto(benchQueryValue)
+	}
+}
+
+func BenchmarkAuto_Baseline(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		builder.AutoBaseline(builder.New(), benchQueryValue)
+	}
+}
+
+func BenchmarkAuto_Build(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		builder.New().Auto(benchQueryValue).Build()
+	}
+}
+
+func BenchmarkAuto_BaselineBuild(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		builder.AutoBaseline(builder.New(), benchQueryValue).Build()
+	}
+}
@@ -20,27 +20,76 @@ const (
 	_in  = "$in"
 	_nin = "$nin"
 
+	_exists = "$exists"
+	_type   = "$type"
+
+	_all       = "$all"
+	_size      = "$size"
+	_elemMatch = "$elemMatch"
+
+	_near          = "$near"
+	_nearSphere    = "$nearSphere"
+	_geoWithin     = "$geoWithin"
+	_geoIntersects = "$geoIntersects"
+	_geometry      = "$geometry"
+	_minDistance   = "$minDistance"
+	_maxDistance   = "$maxDistance"
+	_box           = "$box"
+	_centerSphere  = "$centerSphere"
+
+	_text               = "$text"
+	_search             = "$search"
+	_language           = "$language"
+	_caseSensitive      = "$caseSensitive"
+	_diacriticSensitive = "$diacriticSensitive"
+
+	_expr     = "$expr"
+	_literal  = "$literal"
+	_add      = "$add"
+	_subtract = "$subtract"
+	_multiply = "$multiply"
+	_divide   = "$divide"
+	_strLenCP = "$strLenCP"
+	_dateDiff = "$dateDiff"
+
+	_jsonSchema = "$jsonSchema"
+
+	_mod = "$mod"
+
+	_bitsAllSet   = "$bitsAllSet"
+	_bitsAnySet   = "$bitsAnySet"
+	_bitsAllClear = "$bitsAllClear"
+	_bitsAnyClear = "$bitsAnyClear"
+
 	_regex = "$regex"
 	_not   = "$not"
 
-	_or = "$or"
+	_and = "$and"
+	_or  = "$or"
+	_nor = "$nor"
 )
 
 // Builder represents a filter builder.
 type Builder struct {
-	// condMaps stores all condition maps.
-	condMaps []bson.M
-	// curMap represents the currently operated condition map.
-	// A condition map can be a single element map also can be a multiple elements map.
-	curMap bson.M
+	// branches stores all condition branches separated by Or.
+	branches []*And
+	// cur represents the currently operated condition branch.
+	// Conditions are kept in the order of adding.
+	cur *And
+	// strict indicates whether a failed condition leads to a panic or is recorded to errs.
+	strict bool
+	// errs stores errors of failed conditions in non-strict mode.
+	errs []error
+	// texts counts the `$text` in branches and cur, so checkText doesn't need to walk them.
+	texts int
 }
 
 // New constructs a new Builder.
 func New() *Builder {
-	maps := []bson.M{}
 	return &Builder{
-		condMaps: maps,
-		curMap:   bson.M{},
+		branches: []*And{},
+		cur:      &And{},
+		strict:   true,
 	}
 }
 
@@ -51,8 +100,10 @@ func Auto(val any) *Builder {
 
 // Flush restes the builder to initial state
 func (b *Builder) Flush() *Builder {
-	b.condMaps = []bson.M{}
-	b.curMap = bson.M{}
+	b.branches = []*And{}
+	b.cur = &And{}
+	b.errs = nil
+	b.texts = 0
 	return b
 }
 
@@ -71,7 +122,9 @@ func (b *Builder) Flush() *Builder {
 //   - a nil pointer:
 //     do nothing.
 //
-// *Anything else will lead to a panic.
+// *Anything else will lead to a panic in strict mode.
+//
+// Fields of each struct type are resolved once and cached, Auto is safe to be called concurrently on different builders.
 func (b *Builder) Auto(queryStruct any) *Builder {
 	val := reflect.ValueOf(queryStruct)
 	for val.Kind() == reflect.Pointer {
@@ -81,40 +134,48 @@ func (b *Builder) Auto(queryStruct any) *Builder {
 		val = val.Elem()
 	}
 	if val.Kind() != reflect.Struct {
-		panic("the given value is not struct")
-	}
-
-	fields := reflect.VisibleFields(val.Type())
-	nonZeroFields := []reflect.StructField{}
-	for _, _f := range fields {
-		f := val.FieldByName(_f.Name)
-		if f.IsZero() {
-			continue
-		}
-		nonZeroFields = append(nonZeroFields, _f)
+		b.fail("", "Auto", errNotStruct)
+		return b
 	}
 
-	for _, _f := range nonZeroFields {
-
-		v := val.FieldByName(_f.Name)
-
-		bsonTag, ok := _f.Tag.Lookup("bson")
-		key, _, _ := strings.Cut(bsonTag, ",")
-		if key == "-" {
+	for _, f := range autoFields(val.Type()) {
+		v, err := val.FieldByIndexErr(f.index)
+		if err != nil || v.IsZero() { // skip fields of nil embedded pointers
 			continue
 		}
-		if !ok || key == "" {
-			// use camel case as default
-			b.AutoWithKey(strcase.ToSnake(_f.Name), v.Interface())
-			continue
+		switch f.op {
+		case autoEq:
+			b.Any(f.key).Eq(v.Interface())
+		case autoIn:
+			if v.Len() != 0 {
+				b.Any(f.key).In(v.Interface())
+			}
+		default:
+			b.AutoWithKey(f.key, v.Interface())
 		}
-
-		b.AutoWithKey(key, v.Interface())
 	}
 
 	return b
 }
 
+// fieldKey returns the key of the struct field used in filters.
+//
+// If bson tag is provided on field, the tag will be used as the key,
+// otherwise snake case of field's name will be used as default.
+// ok is false if the field is ignored by `bson:"-"`.
+func fieldKey(f reflect.StructField) (key string, ok bool) {
+	bsonTag, hasTag := f.Tag.Lookup("bson")
+	key, _, _ = strings.Cut(bsonTag, ",")
+	if key == "-" {
+		return "", false
+	}
+	if !hasTag || key == "" {
+		// use snake case as default
+		return strcase.ToSnake(f.Name), true
+	}
+	return key, true
+}
+
 // AutoWithKey try to add eq cond with provided key and val.
 //
 // If val is a pointer:
@@ -173,6 +234,14 @@ func (b *Builder) Num(key string) *numCond {
 	return newNumCond(key, b)
 }
 
+// Partition builds `key % workers == index` condition,
+// thus a fleet of workers can share the same filter by using their own index.
+//
+// workers should be positive and index should be in [0, workers), otherwise the condition fails.
+func (b *Builder) Partition(key string, workers, index int) *Builder {
+	return b.Num(key).Mod(int64(workers), int64(index))
+}
+
 func (b *Builder) Date(key string, defaultFormat ...string) *dateCond {
 	return newDateCond(key, b, defaultFormat...)
 }
@@ -185,59 +254,279 @@ func (b *Builder) Oid(key ...string) *oidCond {
 	return newOidCond("_id", b)
 }
 
+// Arr indicates the builder to build a condition for array type.
+func (b *Builder) Arr(key string) *arrCond {
+	return newArrCond(key, b)
+}
+
+// Geo indicates the builder to build a geospatial condition.
+func (b *Builder) Geo(key string) *geoCond {
+	return newGeoCond(key, b)
+}
+
+// Field indicates the builder to build a condition about the existence, null or BSON type of a field.
+func (b *Builder) Field(key string) *fieldCond {
+	return newFieldCond(key, b)
+}
+
 // Any constructs a condition without type restricted.
 func (b *Builder) Any(key string) *cond {
 	return newCond(key, b)
 }
 
-// Or appends b.curMap to b.condMaps, and b.curMap will be assigned to a new empty map.
-// Thus if finally b.condMaps's len is bigger than 1, then the final filter will wraps all maps into a $or condition.
+// Or appends b.cur to b.branches, and b.cur will be assigned to a new empty branch.
+// Thus if finally b.branches's len is bigger than 1, then the final filter will wraps all branches into a $or condition.
 func (b *Builder) Or() *Builder {
-	if len(b.curMap) == 0 {
+	if len(b.cur.Children) == 0 {
+		return b
+	}
+	b.branches = append(b.branches, b.cur)
+	b.cur = &And{}
+	return b
+}
+
+// AndGroup builds a nested group with fn and ANDs it with the current condition.
+//
+// The filter built by fn is kept as a whole inside `$and`, so Or() can be used
+// inside fn to express something like `a AND (b OR c)`.
+//
+// If fn fails any condition in non-strict mode, the whole group is skipped like ElemMatch,
+// as the rest of the group would match other documents than expected. The same applies to OrGroup and NorGroup.
+func (b *Builder) AndGroup(fn func(*Builder)) *Builder {
+	sub := b.sub(fn)
+	if sub.isEmpty() || len(sub.errs) != 0 {
+		return b
+	}
+	return b.add("", _and, asAnd(sub.AST()))
+}
+
+// OrGroup builds a nested group with fn and ANDs `$or: [...]` with the current condition.
+//
+// Each branch of the group is separated by Or() inside fn, eg:
+//
+//	b.Str("status").Eq("active").OrGroup(func(g *Builder) {
+//		g.Str("owner").Eq("me").Or().Any("shared").Eq(true)
+//	})
+//
+// If `$or` has been set already, the group will be appended to `$and`.
+func (b *Builder) OrGroup(fn func(*Builder)) *Builder {
+	sub := b.sub(fn)
+	if sub.isEmpty() || len(sub.errs) != 0 {
+		return b
+	}
+	return b.add("", _or, &Or{Children: sub.branchNodes()})
+}
+
+// NorGroup is like OrGroup but wraps the branches into `$nor: [...]`.
+func (b *Builder) NorGroup(fn func(*Builder)) *Builder {
+	sub := b.sub(fn)
+	if sub.isEmpty() || len(sub.errs) != 0 {
+		return b
+	}
+	return b.add("", _nor, &Nor{Children: sub.branchNodes()})
+}
+
+// add appends n to the current condition, the failure is reported with key and op.
+func (b *Builder) add(key, op string, n Node) *Builder {
+	texts, ok := b.checkText(key, op, n, nil)
+	if !ok {
+		return b
+	}
+	b.texts = texts
+	b.cur = &And{Children: append(b.cur.Children[:len(b.cur.Children):len(b.cur.Children)], n)}
+	return b
+}
+
+// put adds a field-level node to the current condition.
+// If the same operator of the same field exists, it will be replaced in place.
+func (b *Builder) put(n Node) *Builder {
+	field, op, _ := fieldOp(n)
+	for i, c := range b.cur.Children {
+		if f, o, ok := fieldOp(c); ok && f == field && o == op {
+			return b.replace(i, n)
+		}
+	}
+	return b.add(field, op, n)
+}
+
+// replace replaces the ith node of the current condition with n.
+func (b *Builder) replace(i int, n Node) *Builder {
+	texts, ok := b.checkText("", "", n, b.cur.Children[i])
+	if !ok {
+		return b
+	}
+	b.texts = texts
+	children := append([]Node{}, b.cur.Children...)
+	children[i] = n
+	b.cur = &And{Children: children}
+	return b
+}
+
+// replaceNode replaces old in the current condition with n, n is added if old is not found.
+func (b *Builder) replaceNode(old, n Node) *Builder {
+	if old != nil {
+		for i, c := range b.cur.Children {
+			if c == old {
+				return b.replace(i, n)
+			}
+		}
+	}
+	field, op, _ := fieldOp(n)
+	return b.add(field, op, n)
+}
+
+// fieldNode returns the field-level node of field and op in the current condition.
+func (b *Builder) fieldNode(field, op string) Node {
+	for _, c := range b.cur.Children {
+		if f, o, ok := fieldOp(c); ok && f == field && o == op {
+			return c
+		}
+	}
+	return nil
+}
+
+// allBranches returns all non-empty branches without modifying the builder.
+func (b *Builder) allBranches() []*And {
+	res := append([]*And{}, b.branches...)
+	if len(b.cur.Children) != 0 {
+		res = append(res, b.cur)
+	}
+	return res
+}
+
+// branchNodes returns all non-empty branches as nodes.
+func (b *Builder) branchNodes() []Node {
+	branches := b.allBranches()
+	nodes := make([]Node, 0, len(branches))
+	for _, br := range branches {
+		nodes = append(nodes, br)
+	}
+	return nodes
+}
+
+// isEmpty reports whether the builder has no condition.
+func (b *Builder) isEmpty() bool {
+	return len(b.allBranches()) == 0
+}
+
+// AST returns the expression tree of the builder.
+//
+// The root is an *And if there is only one branch, otherwise it's an *Or of all branches.
+// The tree shares nodes with the builder, thus it should not be modified, see Rewrite.
+func (b *Builder) AST() Node {
+	branches := b.allBranches()
+	switch len(branches) {
+	case 0:
+		return &And{}
+	case 1:
+		return branches[0]
+	}
+	return &Or{Children: b.branchNodes()}
+}
+
+// FromAST constructs a new Builder from the expression tree n.
+//
+// If n is an *Or, each child will be a branch separated by Or(),
+// and the last one will be the current condition.
+func FromAST(n Node) *Builder {
+	b := New()
+	b.texts = countText(n)
+	or, ok := n.(*Or)
+	if !ok {
+		b.cur = asAnd(n)
 		return b
 	}
-	b.condMaps = append(b.condMaps, b.curMap)
-	b.curMap = bson.M{}
+	for i, c := range or.Children {
+		if i == len(or.Children)-1 {
+			b.cur = asAnd(c)
+			break
+		}
+		b.branches = append(b.branches, asAnd(c))
+	}
 	return b
 }
 
+// asAnd wraps n into an *And if it isn't.
+func asAnd(n Node) *And {
+	switch n := n.(type) {
+	case nil:
+		return &And{}
+	case *And:
+		return n
+	}
+	return &And{Children: []Node{n}}
+}
+
 // AnyMap will set the given map to current condition.
+//
+// If m is an operator map such as {$gt: 1}, each operator will be added as a condition of key,
+// otherwise m will be used as the literal value of key.
 func (b *Builder) AnyMap(key string, m bson.M) *Builder {
-	b.curMap[key] = m
-	return b
+	if d := sortedDoc(m); isOpsDoc(d) {
+		for _, n := range importOps(key, d) {
+			b.put(n)
+		}
+		return b
+	}
+	return b.put(&Compare{Field: key, Value: m})
 }
 
 // RemoveCond removes given key that has been added to the builder.
 // Elimination will across all conditions if acrossOrCond is given true.
 func (b *Builder) RemoveCond(key string, acrossOrCond ...bool) *Builder {
-	for k := range b.curMap {
-		if k == key {
-			delete(b.curMap, k)
-		}
-	}
+	b.cur = removeField(b.cur, key)
 
 	if len(acrossOrCond) != 0 && acrossOrCond[0] {
-		for _, condMap := range b.condMaps {
-			for k := range condMap {
-				if k == key {
-					delete(condMap, k)
-				}
-			}
+		// branches may be shared with a Frozen snapshot, so a new slice is built instead of writing in place
+		branches := make([]*And, 0, len(b.branches))
+		for _, branch := range b.branches {
+			branches = append(branches, removeField(branch, key))
 		}
+		b.branches = branches
 	}
+	b.texts = countText(b.branchNodes()...)
 
 	return b
 }
 
+// removeField returns a copy of a without field-level nodes of field.
+func removeField(a *And, field string) *And {
+	res := &And{Children: make([]Node, 0, len(a.Children))}
+	for _, n := range a.Children {
+		if f, _, ok := fieldOp(n); ok && f == field {
+			continue
+		}
+		res.Children = append(res.Children, n)
+	}
+	return res
+}
+
 // Build builds final filter and returns it as bson.M.
+//
+// Build can be called any number of times, and the returned filter is a deep copy
+// which never shares values with the builder.
+//
+// Placeholders of Param must be bound with Template instead,
+// an unbound placeholder leads to a panic in strict mode, otherwise the condition using it is skipped
+// and reported by BuildE. Building never changes the builder.
 func (b *Builder) Build() bson.M {
-	if len(b.curMap) != 0 {
-		b.condMaps = append(b.condMaps, b.curMap)
-	}
-	if len(b.condMaps) > 1 {
+	return toM(b.BuildD())
+}
 
-		return bson.M{_or: b.condMaps}
+// BuildD builds final filter and returns it as bson.D.
+// Fields and operators are ordered as they are added,
+// so the same builder chains always produce the same BSON.
+// Like Build, the result is a deep copy.
+func (b *Builder) BuildD() bson.D {
+	d, _ := b.build()
+	return d
+}
+
+// build renders the filter without conditions using placeholders, and returns errors of them.
+func (b *Builder) build() (bson.D, []error) {
+	root, errs := stripUnbound(b.AST())
+	if len(errs) != 0 && b.strict {
+		panic(errs[0])
 	}
-	res := b.curMap
-	return res
+	return render(root), errs
 }
@@ -7,34 +7,15 @@ import (
 	"time"
 
 	builder "github.com/JsyTech/mongo-filter-builder"
+	"github.com/JsyTech/mongo-filter-builder/buildertest"
 	"github.com/stretchr/testify/assert"
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var db *mongo.Database
-
-const dbCollName = "_filter_builder_test"
+var coll *buildertest.Collection
 
 func setup() {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI("mongodb://localhost:27017"))
-	if err != nil {
-		panic(err)
-	}
-
-	d := client.Database(dbCollName)
-	db = d
-	err = d.Drop(ctx)
-	if err != nil {
-		panic(err)
-	}
-
-	coll := d.Collection(dbCollName)
-
-	_, err = coll.InsertMany(ctx, []interface{}{
+	coll = buildertest.NewCollection(
 		bson.M{"name": "a", "capName": "A", "age": 8, "birthdate": time.Now()},
 		bson.M{"name": "aa", "capName": "AA", "age": 58, "birthdate": time.Now()},
 		bson.M{"name": "b", "capName": "B", "age": 18, "birthdate": time.Now().Add(24 * time.Hour)},
@@ -42,16 +23,11 @@ func setup() {
 		bson.M{"name": "c", "capName": "C", "age": 38, "birthdate": time.Now().Add(3 * 24 * time.Hour)},
 		bson.M{"name": "d", "capName": "D", "age": 28, "birthdate": time.Now().Add(6 * 24 * time.Hour)},
 		bson.M{"name": "ab", "capName": "AB", "age": 5, "birthdate": time.Now()},
-	})
-	if err != nil {
-		panic(err)
-	}
+	)
 }
 
 func tearDown() {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	err := db.Drop(ctx)
+	err := coll.Drop(context.Background())
 	if err != nil {
 		panic(err)
 	}
@@ -62,7 +38,7 @@ func fetchData(filter interface{}) []interface{} {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	c, err := db.Collection(dbCollName).Find(context.Background(), filter)
+	c, err := coll.Find(ctx, filter)
 	if err != nil {
 		panic(err)
 	}
@@ -223,3 +199,123 @@ func TestBuilder_RemoveCond(t *testing.T) {
 	assert.Equal(t, c, b)
 
 }
+
+func TestBuilder_Groups(t *testing.T) {
+	b := builder.New().
+		Str("status").Eq("active").
+		OrGroup(func(g *builder.Builder) {
+			g.Str("owner").Eq("me").Or().Any("shared").Eq(true)
+		}).
+		Build()
+	c := bson.M{
+		"status": bson.M{"$eq": "active"},
+		"$or": []bson.M{
+			{"owner": bson.M{"$eq": "me"}},
+			{"shared": bson.M{"$eq": true}},
+		},
+	}
+	assert.Equal(t, c, b)
+
+	// a second $or group on the same level goes to $and
+	b = builder.New().
+		OrGroup(func(g *builder.Builder) {
+			g.Num("a").Eq(1).Or().Num("b").Eq(1)
+		}).
+		OrGroup(func(g *builder.Builder) {
+			g.Num("c").Eq(1).Or().Num("d").Eq(1)
+		}).
+		Build()
+	c = bson.M{
+		"$or": []bson.M{
+			{"a": bson.M{"$eq": 1}},
+			{"b": bson.M{"$eq": 1}},
+		},
+		"$and": []bson.M{
+			{"$or": []bson.M{
+				{"c": bson.M{"$eq": 1}},
+				{"d": bson.M{"$eq": 1}},
+			}},
+		},
+	}
+	assert.Equal(t, c, b)
+
+	// nested groups
+	b = builder.New().
+		Num("age").Gte(18).
+		AndGroup(func(g *builder.Builder) {
+			g.Str("name").Eq("a").
+				NorGroup(func(g *builder.Builder) {
+					g.Str("tag").Eq("x").Or().Str("tag").Eq("y")
+				}).
+				Or().
+				Str("name").Eq("b")
+		}).
+		Build()
+	c = bson.M{
+		"age": bson.M{"$gte": 18},
+		"$and": []bson.M{
+			{"$or": []bson.M{
+				{
+					"name": bson.M{"$eq": "a"},
+					"$nor": []bson.M{
+						{"tag": bson.M{"$eq": "x"}},
+						{"tag": bson.M{"$eq": "y"}},
+					},
+				},
+				{"name": bson.M{"$eq": "b"}},
+			}},
+		},
+	}
+	assert.Equal(t, c, b)
+
+	// empty groups are ignored
+	b = builder.New().OrGroup(func(g *builder.Builder) {}).Build()
+	assert.Equal(t, bson.M{}, b)
+}
+
+func TestBuilder_BuildD(t *testing.T) {
+	build := func() bson.D {
+		return builder.New().
+			Str("name").Eq("a").
+			Num("age").Gte(18).
+			Num("age").Lte(60).
+			Text("coffee", builder.TextLanguage("en"), builder.TextCaseSensitive(true)).
+			Geo("loc").Near(builder.NewPoint(1, 2), 0, 100).
+			Or().
+			Str("name").Eq("b").
+			BuildD()
+	}
+	d := build()
+	c := bson.D{{Key: "$or", Value: []bson.D{
+		{
+			{Key: "name", Value: bson.D{{Key: "$eq", Value: "a"}}},
+			{Key: "age", Value: bson.D{{Key: "$gte", Value: 18}, {Key: "$lte", Value: 60}}},
+			{Key: "$text", Value: bson.D{
+				{Key: "$search", Value: "coffee"},
+				{Key: "$language", Value: "en"},
+				{Key: "$caseSensitive", Value: true},
+			}},
+			{Key: "loc", Value: bson.D{{Key: "$near", Value: bson.D{
+				{Key: "$geometry", Value: bson.D{{Key: "type", Value: "Point"}, {Key: "coordinates", Value: builder.NewPoint(1, 2)}}},
+				{Key: "$maxDistance", Value: float64(100)},
+			}}}},
+		},
+		{
+			{Key: "name", Value: bson.D{{Key: "$eq", Value: "b"}}},
+		},
+	}}}
+	assert.Equal(t, c, d)
+
+	// identical chains serialise to identical bytes
+	raw1, err := bson.Marshal(build())
+	assert.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		raw2, err := bson.Marshal(build())
+		assert.NoError(t, err)
+		assert.Equal(t, raw1, raw2)
+	}
+
+	// user values are kept as they are
+	d = bson.D{{Key: "a", Value: 1}, {Key: "b", Value: 2}}
+	assert.Equal(t, bson.M{"doc": bson.M{"$eq": d}}, builder.New().Any("doc").Eq(d).Build())
+}
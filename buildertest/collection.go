@@ -0,0 +1,598 @@
+// Package buildertest provides utilities for testing code that uses filters of the builder without a MongoDB server.
+package buildertest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	builder "github.com/JsyTech/mongo-filter-builder"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Collection is an in-memory collection evaluating filters with builder.Match.
+//
+// Documents are kept in the order of insertion, which is also the natural order of Find.
+// A Collection is safe for concurrent use.
+type Collection struct {
+	mu   sync.RWMutex
+	docs []bson.D
+}
+
+// NewCollection constructs a new Collection seeded with docs, see InsertMany.
+// *A document which can't be marshaled to BSON will lead to a panic.
+func NewCollection(docs ...interface{}) *Collection {
+	c := &Collection{}
+	if len(docs) != 0 {
+		if _, err := c.InsertMany(context.Background(), docs); err != nil {
+			panic(err)
+		}
+	}
+	return c
+}
+
+// InsertOne inserts a document, an ObjectID is generated as `_id` if it's absent.
+func (c *Collection) InsertOne(ctx context.Context, doc interface{}) (*mongo.InsertOneResult, error) {
+	res, err := c.InsertMany(ctx, []interface{}{doc})
+	if err != nil {
+		return nil, err
+	}
+	return &mongo.InsertOneResult{InsertedID: res.InsertedIDs[0]}, nil
+}
+
+// InsertMany inserts documents, an ObjectID is generated as `_id` if it's absent.
+// No document is inserted if any of them fails.
+func (c *Collection) InsertMany(ctx context.Context, docs []interface{}) (*mongo.InsertManyResult, error) {
+	normalized := make([]bson.D, 0, len(docs))
+	ids := make([]interface{}, 0, len(docs))
+	for i, doc := range docs {
+		d, err := toDoc(doc)
+		if err != nil {
+			return nil, fmt.Errorf("buildertest: failed to insert document %d, err: %v", i, err)
+		}
+		id, ok := get(d, "_id")
+		if !ok {
+			id = primitive.NewObjectID()
+			d = append(bson.D{{Key: "_id", Value: id}}, d...)
+		}
+		normalized = append(normalized, d)
+		ids = append(ids, id)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.docs = append(c.docs, normalized...)
+	return &mongo.InsertManyResult{InsertedIDs: ids}, nil
+}
+
+// Find returns the documents matched by filter.
+// Sort, Skip, Limit and Projection of opts are supported.
+func (c *Collection) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (*Cursor, error) {
+	o := options.MergeFindOptions(opts...)
+	docs, err := c.find(filter, o.Sort, o.Skip, o.Limit)
+	if err != nil {
+		return nil, err
+	}
+	if o.Projection != nil {
+		if docs, err = project(docs, o.Projection); err != nil {
+			return nil, err
+		}
+	}
+	return &Cursor{docs: docs, pos: -1}, nil
+}
+
+// FindOne returns the first document matched by filter.
+// Sort, Skip and Projection of opts are supported.
+func (c *Collection) FindOne(ctx context.Context, filter interface{}, opts ...*options.FindOneOptions) *SingleResult {
+	findOpts := options.Find().SetLimit(1)
+	for _, o := range opts {
+		if o == nil {
+			continue
+		}
+		if o.Sort != nil {
+			findOpts.SetSort(o.Sort)
+		}
+		if o.Skip != nil {
+			findOpts.SetSkip(*o.Skip)
+		}
+		if o.Projection != nil {
+			findOpts.SetProjection(o.Projection)
+		}
+	}
+	cur, err := c.Find(ctx, filter, findOpts)
+	if err != nil {
+		return &SingleResult{err: err}
+	}
+	if len(cur.docs) == 0 {
+		return &SingleResult{err: mongo.ErrNoDocuments}
+	}
+	return &SingleResult{doc: cur.docs[0]}
+}
+
+// CountDocuments returns the number of documents matched by filter.
+// Skip and Limit of opts are supported.
+func (c *Collection) CountDocuments(ctx context.Context, filter interface{}, opts ...*options.CountOptions) (int64, error) {
+	o := options.MergeCountOptions(opts...)
+	docs, err := c.find(filter, nil, o.Skip, o.Limit)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(docs)), nil
+}
+
+// DeleteOne deletes the first document matched by filter.
+func (c *Collection) DeleteOne(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	return c.delete(filter, 1)
+}
+
+// DeleteMany deletes all documents matched by filter.
+func (c *Collection) DeleteMany(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	return c.delete(filter, -1)
+}
+
+// Drop deletes all documents.
+func (c *Collection) Drop(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.docs = nil
+	return nil
+}
+
+// delete deletes at most limit documents matched by filter, a negative limit means no limit.
+func (c *Collection) delete(filter interface{}, limit int) (*mongo.DeleteResult, error) {
+	f, err := toFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	remain := make([]bson.D, 0, len(c.docs))
+	deleted := 0
+	for _, d := range c.docs {
+		if limit < 0 || deleted < limit {
+			ok, err := builder.Match(f, d)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				deleted++
+				continue
+			}
+		}
+		remain = append(remain, d)
+	}
+	c.docs = remain
+	return &mongo.DeleteResult{DeletedCount: int64(deleted)}, nil
+}
+
+// find returns the matched documents sorted by sortSpec, then skips and limits them.
+func (c *Collection) find(filter, sortSpec interface{}, skip, limit *int64) ([]bson.D, error) {
+	f, err := toFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	res := []bson.D{}
+	for _, d := range c.docs {
+		ok, err := builder.Match(f, d)
+		if err != nil {
+			c.mu.RUnlock()
+			return nil, err
+		}
+		if ok {
+			res = append(res, d)
+		}
+	}
+	c.mu.RUnlock()
+
+	if sortSpec != nil {
+		if err := sortDocs(res, sortSpec); err != nil {
+			return nil, err
+		}
+	}
+	if skip != nil && *skip > 0 {
+		if *skip >= int64(len(res)) {
+			return []bson.D{}, nil
+		}
+		res = res[*skip:]
+	}
+	if limit != nil && *limit != 0 {
+		n := *limit
+		// a negative limit asks the server to return a single batch of |limit| documents and close the cursor,
+		// without batching here it's approximated as a limit of |limit|
+		if n < 0 {
+			n = -n
+		}
+		if n < int64(len(res)) {
+			res = res[:n]
+		}
+	}
+	return res, nil
+}
+
+// toFilter converts a filter such as bson.M, bson.D or nil to bson.M.
+func toFilter(filter interface{}) (bson.M, error) {
+	switch f := filter.(type) {
+	case nil:
+		return bson.M{}, nil
+	case bson.M:
+		return f, nil
+	}
+	data, err := bson.Marshal(filter)
+	if err != nil {
+		return nil, fmt.Errorf("buildertest: invalid filter, err: %v", err)
+	}
+	var m bson.M
+	if err := bson.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("buildertest: invalid filter, err: %v", err)
+	}
+	return m, nil
+}
+
+// toDoc converts v to bson.D with values of the types decoded by the driver.
+func toDoc(v interface{}) (bson.D, error) {
+	data, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var d bson.D
+	if err := bson.Unmarshal(data, &d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// get returns the value of key in d.
+func get(d bson.D, key string) (interface{}, bool) {
+	for _, e := range d {
+		if e.Key == key {
+			return e.Value, true
+		}
+	}
+	return nil, false
+}
+
+// sortDocs sorts docs by the sort document such as bson.D{{"age", -1}, {"name", 1}}.
+// Ties are kept in the natural order.
+//
+// Like MongoDB, an array is sorted by its smallest element in ascending order and its largest in descending order,
+// and a missing field is sorted as null.
+func sortDocs(docs []bson.D, sortSpec interface{}) error {
+	spec, err := toDoc(sortSpec)
+	if err != nil {
+		return fmt.Errorf("buildertest: invalid sort, err: %v", err)
+	}
+	dirs := make([]int, 0, len(spec))
+	for _, e := range spec {
+		dir, ok := direction(e.Value)
+		if !ok {
+			return fmt.Errorf("buildertest: sort direction of %s should be 1 or -1, got %v", e.Key, e.Value)
+		}
+		dirs = append(dirs, dir)
+	}
+
+	keys := make([][]interface{}, len(docs))
+	for i, d := range docs {
+		keys[i] = make([]interface{}, len(spec))
+		for j, e := range spec {
+			keys[i][j] = sortKey(lookup(d, strings.Split(e.Key, ".")), dirs[j])
+		}
+	}
+	idx := make([]int, len(docs))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(x, y int) bool {
+		for j, dir := range dirs {
+			if cmp := builder.CompareValues(keys[idx[x]][j], keys[idx[y]][j]) * dir; cmp != 0 {
+				return cmp < 0
+			}
+		}
+		return false
+	})
+
+	sorted := make([]bson.D, len(docs))
+	for i, j := range idx {
+		sorted[i] = docs[j]
+	}
+	copy(docs, sorted)
+	return nil
+}
+
+// direction converts a sort direction to 1 or -1.
+func direction(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int32:
+		if n == 1 || n == -1 {
+			return int(n), true
+		}
+	case int64:
+		if n == 1 || n == -1 {
+			return int(n), true
+		}
+	case float64:
+		if n == 1 || n == -1 {
+			return int(n), true
+		}
+	}
+	return 0, false
+}
+
+// lookup returns the values at path in v, arrays are traversed and their elements are returned.
+func lookup(v interface{}, path []string) []interface{} {
+	switch v := v.(type) {
+	case bson.A:
+		res := []interface{}{}
+		for _, e := range v {
+			res = append(res, lookup(e, path)...)
+		}
+		return res
+	case bson.D:
+		if len(path) == 0 {
+			return []interface{}{v}
+		}
+		field, ok := get(v, path[0])
+		if !ok {
+			return nil
+		}
+		return lookup(field, path[1:])
+	}
+	if len(path) == 0 {
+		return []interface{}{v}
+	}
+	return nil
+}
+
+// sortKey returns the smallest value in ascending order or the largest in descending order.
+func sortKey(vals []interface{}, dir int) interface{} {
+	if len(vals) == 0 {
+		return nil
+	}
+	key := vals[0]
+	for _, v := range vals[1:] {
+		if builder.CompareValues(v, key)*dir < 0 {
+			key = v
+		}
+	}
+	return key
+}
+
+// project applies an inclusion or exclusion projection to docs.
+// `_id` is included unless it's excluded explicitly.
+func project(docs []bson.D, projection interface{}) ([]bson.D, error) {
+	spec, err := toDoc(projection)
+	if err != nil {
+		return nil, fmt.Errorf("buildertest: invalid projection, err: %v", err)
+	}
+
+	var included, excluded []string
+	idSpecified, excludeID := false, false
+	for _, e := range spec {
+		on := truthy(e.Value)
+		switch {
+		case e.Key == "_id":
+			idSpecified, excludeID = true, !on
+		case on:
+			included = append(included, e.Key)
+		default:
+			excluded = append(excluded, e.Key)
+		}
+	}
+	if len(included) != 0 && len(excluded) != 0 {
+		return nil, errors.New("buildertest: projection can't mix inclusion and exclusion")
+	}
+	// {_id: 1} only includes _id
+	inclusion := len(included) != 0 || (idSpecified && !excludeID && len(excluded) == 0)
+	if !inclusion && excludeID {
+		excluded = append(excluded, "_id")
+	}
+
+	res := make([]bson.D, 0, len(docs))
+	for _, d := range docs {
+		if inclusion {
+			paths := [][]string{}
+			if !excludeID {
+				paths = append(paths, []string{"_id"})
+			}
+			for _, key := range included {
+				paths = append(paths, strings.Split(key, "."))
+			}
+			res = append(res, includePaths(d, paths))
+			continue
+		}
+		for _, key := range excluded {
+			d = excludePath(d, strings.Split(key, "."))
+		}
+		res = append(res, d)
+	}
+	return res, nil
+}
+
+// truthy reports whether a projection value includes the field.
+func truthy(v interface{}) bool {
+	switch n := v.(type) {
+	case bool:
+		return n
+	case int32:
+		return n != 0
+	case int64:
+		return n != 0
+	case float64:
+		return n != 0
+	}
+	return true
+}
+
+// includePaths returns a copy of d with only the paths, fields are kept in the order of d.
+func includePaths(d bson.D, paths [][]string) bson.D {
+	res := bson.D{}
+	for _, e := range d {
+		var sub [][]string
+		whole := false
+		for _, p := range paths {
+			if p[0] != e.Key {
+				continue
+			}
+			if len(p) == 1 {
+				whole = true
+				break
+			}
+			sub = append(sub, p[1:])
+		}
+		switch {
+		case whole:
+			res = append(res, e)
+		case len(sub) != 0:
+			if v, ok := includeValue(e.Value, sub); ok {
+				res = append(res, bson.E{Key: e.Key, Value: v})
+			}
+		}
+	}
+	return res
+}
+
+// includeValue applies nested paths to documents or arrays of documents.
+func includeValue(v interface{}, paths [][]string) (interface{}, bool) {
+	switch v := v.(type) {
+	case bson.D:
+		return includePaths(v, paths), true
+	case bson.A:
+		res := bson.A{}
+		for _, e := range v {
+			if sub, ok := includeValue(e, paths); ok {
+				res = append(res, sub)
+			}
+		}
+		return res, true
+	}
+	return nil, false
+}
+
+// excludePath returns a copy of d without the path.
+func excludePath(d bson.D, path []string) bson.D {
+	res := make(bson.D, 0, len(d))
+	for _, e := range d {
+		if e.Key != path[0] {
+			res = append(res, e)
+			continue
+		}
+		if len(path) == 1 {
+			continue
+		}
+		res = append(res, bson.E{Key: e.Key, Value: excludeValue(e.Value, path[1:])})
+	}
+	return res
+}
+
+// excludeValue applies a nested path to documents or arrays of documents.
+func excludeValue(v interface{}, path []string) interface{} {
+	switch v := v.(type) {
+	case bson.D:
+		return excludePath(v, path)
+	case bson.A:
+		res := make(bson.A, 0, len(v))
+		for _, e := range v {
+			res = append(res, excludeValue(e, path))
+		}
+		return res
+	}
+	return v
+}
+
+// Cursor iterates documents found by Collection.Find, it mirrors the methods of mongo.Cursor.
+type Cursor struct {
+	docs []bson.D
+	pos  int
+	// Current is the raw document of the current position.
+	Current bson.Raw
+}
+
+// Next moves the cursor to the next document and reports whether there is one.
+func (c *Cursor) Next(ctx context.Context) bool {
+	if c.pos+1 >= len(c.docs) {
+		c.Current = nil
+		return false
+	}
+	c.pos++
+	c.Current, _ = bson.Marshal(c.docs[c.pos])
+	return true
+}
+
+// Decode decodes the current document into v.
+func (c *Cursor) Decode(v interface{}) error {
+	if c.pos < 0 || c.pos >= len(c.docs) {
+		return errors.New("buildertest: no current document")
+	}
+	return decode(c.docs[c.pos], v)
+}
+
+// All decodes the remaining documents into results, which should be a pointer to a slice.
+func (c *Cursor) All(ctx context.Context, results interface{}) error {
+	rv := reflect.ValueOf(results)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("buildertest: results should be a pointer to a slice, got %T", results)
+	}
+	slice := reflect.MakeSlice(rv.Elem().Type(), 0, len(c.docs))
+	for c.Next(ctx) {
+		elem := reflect.New(slice.Type().Elem())
+		if err := c.Decode(elem.Interface()); err != nil {
+			return err
+		}
+		slice = reflect.Append(slice, elem.Elem())
+	}
+	rv.Elem().Set(slice)
+	return nil
+}
+
+// RemainingBatchLength returns the number of remaining documents.
+func (c *Cursor) RemainingBatchLength() int {
+	return len(c.docs) - c.pos - 1
+}
+
+// Err always returns nil since documents are in memory.
+func (c *Cursor) Err() error {
+	return nil
+}
+
+// Close closes the cursor.
+func (c *Cursor) Close(ctx context.Context) error {
+	c.pos = len(c.docs)
+	return nil
+}
+
+// SingleResult is the result of Collection.FindOne, it mirrors the methods of mongo.SingleResult.
+type SingleResult struct {
+	doc bson.D
+	err error
+}
+
+// Decode decodes the document into v, mongo.ErrNoDocuments is returned if no document is found.
+func (r *SingleResult) Decode(v interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	return decode(r.doc, v)
+}
+
+// Err returns the error of the query, mongo.ErrNoDocuments is returned if no document is found.
+func (r *SingleResult) Err() error {
+	return r.err
+}
+
+// decode decodes d into v like the driver.
+func decode(d bson.D, v interface{}) error {
+	data, err := bson.Marshal(d)
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(data, v)
+}
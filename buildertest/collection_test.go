@@ -0,0 +1,119 @@
+package buildertest_test
+
+import (
+	"context"
+	"testing"
+
+	builder "github.com/JsyTech/mongo-filter-builder"
+	"github.com/JsyTech/mongo-filter-builder/buildertest"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type user struct {
+	ID   int      `bson:"_id"`
+	Name string   `bson:"name"`
+	Age  int      `bson:"age"`
+	Tags []string `bson:"tags,omitempty"`
+}
+
+func seed() *buildertest.Collection {
+	return buildertest.NewCollection(
+		user{ID: 1, Name: "a", Age: 30, Tags: []string{"x"}},
+		user{ID: 2, Name: "b", Age: 20},
+		user{ID: 3, Name: "c", Age: 40, Tags: []string{"x", "y"}},
+		user{ID: 4, Name: "d", Age: 20},
+	)
+}
+
+func TestCollection_Find(t *testing.T) {
+	ctx := context.Background()
+	coll := seed()
+
+	cur, err := coll.Find(ctx, builder.New().Num("age").Gte(20).Build(),
+		options.Find().SetSort(bson.D{{Key: "age", Value: -1}, {Key: "name", Value: 1}}).SetSkip(1).SetLimit(2))
+	assert.Nil(t, err)
+	var users []user
+	assert.Nil(t, cur.All(ctx, &users))
+	assert.Equal(t, []user{{ID: 1, Name: "a", Age: 30, Tags: []string{"x"}}, {ID: 2, Name: "b", Age: 20}}, users)
+
+	// a negative limit returns a single batch of at most |limit| documents
+	cur, err = coll.Find(ctx, builder.New().Num("age").Gte(20).Build(),
+		options.Find().SetSort(bson.D{{Key: "age", Value: -1}, {Key: "name", Value: 1}}).SetLimit(-2))
+	assert.Nil(t, err)
+	users = nil
+	assert.Nil(t, cur.All(ctx, &users))
+	assert.Len(t, users, 2)
+
+	// arrays are sorted by their smallest elements in ascending order, missing fields are sorted as null
+	cur, err = coll.Find(ctx, nil, options.Find().SetSort(bson.M{"tags": 1}).SetProjection(bson.M{"name": 1, "_id": 0}))
+	assert.Nil(t, err)
+	var docs []bson.D
+	assert.Nil(t, cur.All(ctx, &docs))
+	assert.Equal(t, []bson.D{
+		{{Key: "name", Value: "b"}},
+		{{Key: "name", Value: "d"}},
+		{{Key: "name", Value: "a"}},
+		{{Key: "name", Value: "c"}},
+	}, docs)
+
+	cur, err = coll.Find(ctx, bson.D{{Key: "tags", Value: "y"}}, options.Find().SetProjection(bson.M{"tags": 0, "age": 0}))
+	assert.Nil(t, err)
+	assert.True(t, cur.Next(ctx))
+	var doc bson.D
+	assert.Nil(t, cur.Decode(&doc))
+	assert.Equal(t, bson.D{{Key: "_id", Value: int32(3)}, {Key: "name", Value: "c"}}, doc)
+	assert.False(t, cur.Next(ctx))
+
+	_, err = coll.Find(ctx, bson.M{"age": bson.M{"$unknown": 1}})
+	assert.NotNil(t, err)
+	_, err = coll.Find(ctx, nil, options.Find().SetProjection(bson.M{"name": 1, "age": 0}))
+	assert.NotNil(t, err)
+}
+
+func TestCollection_FindOne(t *testing.T) {
+	ctx := context.Background()
+	coll := seed()
+
+	var u user
+	err := coll.FindOne(ctx, builder.New().Num("age").Eq(20).Build(), options.FindOne().SetSort(bson.M{"name": -1})).Decode(&u)
+	assert.Nil(t, err)
+	assert.Equal(t, "d", u.Name)
+
+	err = coll.FindOne(ctx, builder.New().Str("name").Eq("z").Build()).Decode(&u)
+	assert.Equal(t, mongo.ErrNoDocuments, err)
+}
+
+func TestCollection_CountAndDelete(t *testing.T) {
+	ctx := context.Background()
+	coll := seed()
+
+	n, err := coll.CountDocuments(ctx, builder.New().Arr("tags").NotEmpty().Build())
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), n)
+
+	n, err = coll.CountDocuments(ctx, nil, options.Count().SetSkip(1).SetLimit(2))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), n)
+
+	res, err := coll.DeleteOne(ctx, builder.New().Num("age").Eq(20).Build())
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), res.DeletedCount)
+
+	res, err = coll.DeleteMany(ctx, builder.New().Num("age").Gte(20).Build())
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), res.DeletedCount)
+
+	n, err = coll.CountDocuments(ctx, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), n)
+
+	inserted, err := coll.InsertOne(ctx, bson.M{"name": "e"})
+	assert.Nil(t, err)
+	assert.NotNil(t, inserted.InsertedID)
+	n, err = coll.CountDocuments(ctx, bson.M{"_id": inserted.InsertedID})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), n)
+}
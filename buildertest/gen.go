@@ -0,0 +1,341 @@
+package buildertest
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	builder "github.com/JsyTech/mongo-filter-builder"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Fields and values used by Gen, values are picked from small domains so that
+// generated filters match a fair share of generated documents.
+const (
+	StrField  = "name"
+	NumField  = "age"
+	DateField = "created"
+	OidField  = "owner"
+	ArrField  = "tags"
+)
+
+var (
+	genStrs  = []string{"a", "b", "c", "ab", "bc", "ca"}
+	genTags  = []string{"x", "y", "z"}
+	genDate  = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	genOids  = []string{"000000000000000000000001", "000000000000000000000002", "000000000000000000000003"}
+	genLimit = 10
+)
+
+// Gen generates random but valid builders and documents from a seed,
+// the same seed always produces the same sequence.
+//
+// Documents have the fields StrField, NumField, DateField, OidField and ArrField,
+// each of them may be missing or null, and numbers may be integers or doubles.
+type Gen struct {
+	// MaxBranches is the maximum number of branches separated by Or(), 3 is used as default.
+	MaxBranches int
+	// MaxConds is the maximum number of conditions in a branch, 3 is used as default.
+	MaxConds int
+	// MaxDepth is the maximum depth of nested groups, 2 is used as default.
+	MaxDepth int
+
+	rand *rand.Rand
+}
+
+// NewGen constructs a new Gen with seed.
+func NewGen(seed int64) *Gen {
+	return &Gen{
+		MaxBranches: 3,
+		MaxConds:    3,
+		MaxDepth:    2,
+		rand:        rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Builder generates a random builder.
+func (g *Gen) Builder() *builder.Builder {
+	b := builder.New()
+	g.fill(b, g.MaxDepth)
+	return b
+}
+
+// fill adds random branches and conditions to b, groups are nested up to depth.
+func (g *Gen) fill(b *builder.Builder, depth int) {
+	branches := 1 + g.rand.Intn(atLeastOne(g.MaxBranches))
+	for i := 0; i < branches; i++ {
+		if i != 0 {
+			b.Or()
+		}
+		conds := 1 + g.rand.Intn(atLeastOne(g.MaxConds))
+		for j := 0; j < conds; j++ {
+			if depth > 0 && g.rand.Intn(5) == 0 {
+				g.group(b, depth-1)
+				continue
+			}
+			g.cond(b)
+		}
+	}
+}
+
+// atLeastOne returns n, or 1 if n is less than 1.
+func atLeastOne(n int) int {
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// group adds a random nested group to b.
+func (g *Gen) group(b *builder.Builder, depth int) {
+	fn := func(sub *builder.Builder) { g.fill(sub, depth) }
+	switch g.rand.Intn(3) {
+	case 0:
+		b.AndGroup(fn)
+	case 1:
+		b.OrGroup(fn)
+	default:
+		b.NorGroup(fn)
+	}
+}
+
+// cond adds a random condition to b.
+func (g *Gen) cond(b *builder.Builder) {
+	switch g.rand.Intn(6) {
+	case 0:
+		g.strCond(b)
+	case 1:
+		g.numCond(b)
+	case 2:
+		g.dateCond(b)
+	case 3:
+		g.oidCond(b)
+	case 4:
+		g.arrCond(b)
+	default:
+		g.fieldCond(b)
+	}
+}
+
+func (g *Gen) strCond(b *builder.Builder) {
+	s := genStrs[g.rand.Intn(len(genStrs))]
+	switch g.rand.Intn(9) {
+	case 0:
+		b.Str(StrField).Eq(s)
+	case 1:
+		b.Str(StrField).Ne(s)
+	case 2:
+		b.Str(StrField).In(g.strs()...)
+	case 3:
+		b.Str(StrField).Nin(g.strs()...)
+	case 4:
+		b.Str(StrField).Regex("^" + s[:1])
+	case 5:
+		b.Str(StrField).NotLike(s)
+	case 6:
+		b.Str(StrField).Negate().In(g.strs()...)
+	case 7:
+		// operators of the same negated condition are wrapped into the same `$not`
+		c := b.Str(StrField).Negate()
+		c.Eq(s)
+		c.In(g.strs()...)
+	default:
+		b.Str(StrField).Negate().Eq(s)
+	}
+}
+
+func (g *Gen) numCond(b *builder.Builder) {
+	n := g.rand.Intn(genLimit)
+	switch g.rand.Intn(9) {
+	case 0:
+		b.Num(NumField).Eq(n)
+	case 1:
+		b.Num(NumField).Ne(n)
+	case 2:
+		b.Num(NumField).Gt(n)
+	case 3:
+		b.Num(NumField).Gte(n)
+	case 4:
+		b.Num(NumField).Lt(n)
+	case 5:
+		b.Num(NumField).Lte(n)
+	case 6:
+		b.Num(NumField).Between(n, n+g.rand.Intn(genLimit))
+	case 7:
+		b.Num(NumField).In(g.nums())
+	default:
+		b.Num(NumField).Negate().Gt(n)
+	}
+}
+
+func (g *Gen) dateCond(b *builder.Builder) {
+	d := g.date()
+	switch g.rand.Intn(5) {
+	case 0:
+		b.Date(DateField).Eq(d)
+	case 1:
+		b.Date(DateField).Gt(d)
+	case 2:
+		b.Date(DateField).Lte(d)
+	case 3:
+		b.Date(DateField).Between(d, d.AddDate(0, 0, g.rand.Intn(genLimit)))
+	default:
+		b.Date(DateField).Negate().Lt(d)
+	}
+}
+
+func (g *Gen) oidCond(b *builder.Builder) {
+	oid := genOids[g.rand.Intn(len(genOids))]
+	if g.rand.Intn(2) == 0 {
+		b.Oid(OidField).Eq(oid)
+		return
+	}
+	b.Oid(OidField).Negate().Eq(oid)
+}
+
+func (g *Gen) arrCond(b *builder.Builder) {
+	tag := genTags[g.rand.Intn(len(genTags))]
+	switch g.rand.Intn(5) {
+	case 0:
+		b.Arr(ArrField).ContainsAny(tag)
+	case 1:
+		b.Arr(ArrField).All(tag, genTags[g.rand.Intn(len(genTags))])
+	case 2:
+		b.Arr(ArrField).Size(g.rand.Intn(3))
+	case 3:
+		b.Arr(ArrField).Empty()
+	default:
+		b.Arr(ArrField).NotEmpty()
+	}
+}
+
+func (g *Gen) fieldCond(b *builder.Builder) {
+	fields := []string{StrField, NumField, DateField, OidField, ArrField}
+	f := fields[g.rand.Intn(len(fields))]
+	switch g.rand.Intn(3) {
+	case 0:
+		b.Field(f).IsNull()
+	case 1:
+		b.Field(f).IsMissing()
+	default:
+		b.Field(f).NotNull()
+	}
+}
+
+// strs returns 1 to 3 random strings.
+func (g *Gen) strs() []string {
+	res := make([]string, 1+g.rand.Intn(3))
+	for i := range res {
+		res[i] = genStrs[g.rand.Intn(len(genStrs))]
+	}
+	return res
+}
+
+// nums returns 1 to 3 random integers.
+func (g *Gen) nums() []int {
+	res := make([]int, 1+g.rand.Intn(3))
+	for i := range res {
+		res[i] = g.rand.Intn(genLimit)
+	}
+	return res
+}
+
+// date returns a random date in genLimit days since genDate.
+func (g *Gen) date() time.Time {
+	return genDate.AddDate(0, 0, g.rand.Intn(genLimit))
+}
+
+// Doc generates a random document.
+func (g *Gen) Doc() bson.D {
+	d := bson.D{}
+	add := func(key string, val func() interface{}) {
+		switch g.rand.Intn(8) {
+		case 0:
+			// missing
+		case 1:
+			d = append(d, bson.E{Key: key, Value: nil})
+		default:
+			d = append(d, bson.E{Key: key, Value: val()})
+		}
+	}
+	add(StrField, func() interface{} { return genStrs[g.rand.Intn(len(genStrs))] })
+	add(NumField, func() interface{} {
+		n := g.rand.Intn(genLimit + 2)
+		if g.rand.Intn(3) == 0 {
+			return float64(n) + 0.5*float64(g.rand.Intn(2))
+		}
+		return int32(n)
+	})
+	add(DateField, func() interface{} { return primitive.NewDateTimeFromTime(g.date()) })
+	add(OidField, func() interface{} {
+		oid, _ := primitive.ObjectIDFromHex(genOids[g.rand.Intn(len(genOids))])
+		return oid
+	})
+	add(ArrField, func() interface{} {
+		tags := bson.A{}
+		for i := g.rand.Intn(4); i > 0; i-- {
+			tags = append(tags, genTags[g.rand.Intn(len(genTags))])
+		}
+		return tags
+	})
+	return d
+}
+
+// Docs generates n random documents.
+func (g *Gen) Docs(n int) []bson.D {
+	docs := make([]bson.D, 0, n)
+	for i := 0; i < n; i++ {
+		docs = append(docs, g.Doc())
+	}
+	return docs
+}
+
+// Equivalent reports whether filters a and b match the same documents of docs,
+// the first document matched by only one of them is returned if they are not equivalent.
+func Equivalent(a, b bson.M, docs []bson.D) (diff bson.D, ok bool, err error) {
+	for _, d := range docs {
+		ma, err := builder.Match(a, d)
+		if err != nil {
+			return nil, false, err
+		}
+		mb, err := builder.Match(b, d)
+		if err != nil {
+			return nil, false, err
+		}
+		if ma != mb {
+			return d, false, nil
+		}
+	}
+	return nil, true, nil
+}
+
+// TestingT is the subset of testing.TB used by assertions.
+type TestingT interface {
+	Errorf(format string, args ...interface{})
+}
+
+// AssertEquivalent asserts filters a and b match the same documents of n documents generated by g.
+func (g *Gen) AssertEquivalent(t TestingT, a, b bson.M, n int) bool {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+	diff, ok, err := Equivalent(a, b, g.Docs(n))
+	if err != nil {
+		t.Errorf("buildertest: failed to match documents, err: %v", err)
+		return false
+	}
+	if !ok {
+		t.Errorf("buildertest: filters are not equivalent\n%s\n%s\ndocument: %s", shell(a), shell(b), fmt.Sprint(diff))
+	}
+	return ok
+}
+
+// shell renders a filter in mongosh syntax, the filter is printed as it is if it can't be imported.
+func shell(filter bson.M) string {
+	b, err := builder.FromBSON(filter)
+	if err != nil {
+		return fmt.Sprint(filter)
+	}
+	return b.ToShell()
+}
@@ -0,0 +1,111 @@
+package buildertest_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/JsyTech/mongo-filter-builder/buildertest"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type recorder struct {
+	errs []string
+}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errs = append(r.errs, fmt.Sprintf(format, args...))
+}
+
+func toInterfaces(docs []bson.D) []interface{} {
+	res := make([]interface{}, len(docs))
+	for i, d := range docs {
+		res[i] = d
+	}
+	return res
+}
+
+func TestGen(t *testing.T) {
+	g := buildertest.NewGen(1)
+	coll := buildertest.NewCollection()
+	_, err := coll.InsertMany(context.Background(), toInterfaces(g.Docs(100)))
+	assert.Nil(t, err)
+	matched := 0
+	for i := 0; i < 100; i++ {
+		filter, err := g.Builder().BuildE()
+		assert.Nil(t, err)
+		n, err := coll.CountDocuments(context.Background(), filter)
+		assert.Nil(t, err)
+		if n > 0 {
+			matched++
+		}
+	}
+	// generated filters should not be trivially unsatisfiable
+	assert.Greater(t, matched, 25)
+}
+
+func TestGen_Deterministic(t *testing.T) {
+	gen := func(seed int64) ([]bson.D, []bson.D) {
+		g := buildertest.NewGen(seed)
+		filters := []bson.D{}
+		for i := 0; i < 50; i++ {
+			filters = append(filters, g.Builder().BuildD())
+		}
+		// builders and docs are drawn from the same source, so they are interleaved here
+		docs := g.Docs(20)
+		filters = append(filters, g.Builder().BuildD())
+		return filters, append(docs, g.Docs(20)...)
+	}
+
+	filters, docs := gen(42)
+	for i := 0; i < 3; i++ {
+		f, d := gen(42)
+		assert.Equal(t, filters, f)
+		assert.Equal(t, docs, d)
+	}
+
+	f, d := gen(43)
+	assert.NotEqual(t, filters, f)
+	assert.NotEqual(t, docs, d)
+}
+
+func TestEquivalent(t *testing.T) {
+	g := buildertest.NewGen(7)
+	docs := g.Docs(50)
+
+	_, ok, err := buildertest.Equivalent(bson.M{"age": bson.M{"$gte": 3, "$lte": 3}}, bson.M{"age": 3}, docs)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	diff, ok, err := buildertest.Equivalent(bson.M{"age": bson.M{"$gt": 3}}, bson.M{"age": bson.M{"$gte": 3}}, docs)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	assert.NotNil(t, diff)
+
+	_, _, err = buildertest.Equivalent(bson.M{"age": bson.M{"$unknown": 3}}, bson.M{}, docs)
+	assert.NotNil(t, err)
+
+	r := &recorder{}
+	assert.False(t, g.AssertEquivalent(r, bson.M{"name": "a"}, bson.M{"name": "b"}, 50))
+	assert.Len(t, r.errs, 1)
+}
+
+func TestGen_Optimize(t *testing.T) {
+	for seed := int64(0); seed < 200; seed++ {
+		g := buildertest.NewGen(seed)
+		b := g.Builder()
+		if !g.AssertEquivalent(t, b.Build(), b.Optimize().Build(), 100) {
+			t.Logf("seed: %d", seed)
+		}
+	}
+}
+
+func TestGen_OptimizeNegated(t *testing.T) {
+	g := buildertest.NewGen(3)
+	g.MaxBranches, g.MaxConds, g.MaxDepth = 1, 1, 0
+	for i := 0; i < 300; i++ {
+		b := g.Builder()
+		g.AssertEquivalent(t, b.Build(), b.Optimize().Build(), 50)
+	}
+}
@@ -0,0 +1,132 @@
+package builder
+
+import "reflect"
+
+// Clone returns a deep copy of the builder, including its conditions, strict mode and recorded errors.
+//
+// The clone and the builder are independent, chaining on one of them never affects the other,
+// so a base builder can be kept and cloned to derive variants.
+func (b *Builder) Clone() *Builder {
+	res := &Builder{
+		branches: make([]*And, 0, len(b.branches)),
+		cur:      cloneNode(b.cur).(*And),
+		strict:   b.strict,
+		errs:     append([]error(nil), b.errs...),
+		texts:    b.texts,
+	}
+	for _, br := range b.branches {
+		res.branches = append(res.branches, cloneNode(br).(*And))
+	}
+	return res
+}
+
+// cloneNode returns a deep copy of n, values given by users are copied as well.
+func cloneNode(n Node) Node {
+	switch n := n.(type) {
+	case *And:
+		return &And{Children: cloneNodes(n.Children)}
+	case *Or:
+		return &Or{Children: cloneNodes(n.Children)}
+	case *Nor:
+		return &Nor{Children: cloneNodes(n.Children)}
+	case *Not:
+		ops := make([]*Compare, 0, len(n.Ops))
+		for _, c := range n.Ops {
+			ops = append(ops, cloneNode(c).(*Compare))
+		}
+		return &Not{Field: n.Field, Ops: ops}
+	case *Compare:
+		return &Compare{Field: n.Field, Op: n.Op, Value: copyValue(n.Value)}
+	case *ElemMatch:
+		return &ElemMatch{Field: n.Field, Filter: cloneNode(n.Filter)}
+	case *Expr:
+		return &Expr{Value: copyValue(n.Value)}
+	case *Text:
+		t := *n
+		if n.CaseSensitive != nil {
+			t.CaseSensitive = boolPtr(*n.CaseSensitive)
+		}
+		if n.DiacriticSensitive != nil {
+			t.DiacriticSensitive = boolPtr(*n.DiacriticSensitive)
+		}
+		return &t
+	case *Raw:
+		return &Raw{Key: n.Key, Value: copyValue(n.Value)}
+	}
+	return n
+}
+
+// cloneNodes returns deep copies of nodes.
+func cloneNodes(nodes []Node) []Node {
+	if nodes == nil {
+		return nil
+	}
+	res := make([]Node, 0, len(nodes))
+	for _, n := range nodes {
+		res = append(res, cloneNode(n))
+	}
+	return res
+}
+
+// boolPtr returns a pointer to v.
+func boolPtr(v bool) *bool {
+	return &v
+}
+
+// copyValue returns a deep copy of v.
+//
+// Slices, maps, arrays and exported fields of structs are copied recursively,
+// pointers and unexported fields are shared with v.
+func copyValue(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	return copyReflect(reflect.ValueOf(v)).Interface()
+}
+
+func copyReflect(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		res := reflect.New(v.Type()).Elem()
+		res.Set(copyReflect(v.Elem()))
+		return res
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		res := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			res.Index(i).Set(copyReflect(v.Index(i)))
+		}
+		return res
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		res := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			res.SetMapIndex(iter.Key(), copyReflect(iter.Value()))
+		}
+		return res
+	case reflect.Array:
+		res := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			res.Index(i).Set(copyReflect(v.Index(i)))
+		}
+		return res
+	case reflect.Struct:
+		res := reflect.New(v.Type()).Elem()
+		res.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				res.Field(i).Set(copyReflect(v.Field(i)))
+			}
+		}
+		return res
+	}
+	return v
+}
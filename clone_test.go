@@ -0,0 +1,68 @@
+package builder_test
+
+import (
+	"testing"
+
+	builder "github.com/JsyTech/mongo-filter-builder"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestBuilder_Clone(t *testing.T) {
+	base := builder.New().Str("name").Eq("a").Or().Num("age").Gt(18)
+	expected := base.Build()
+
+	v1 := base.Clone().Num("age").Lt(30)
+	v2 := base.Clone().Or().Str("name").In("b", "c")
+	base.RemoveCond("name", true)
+
+	assert.Equal(t, bson.M{"$or": []bson.M{
+		{"name": bson.M{"$eq": "a"}},
+		{"age": bson.M{"$gt": 18, "$lt": 30}},
+	}}, v1.Build())
+	assert.Equal(t, bson.M{"$or": []bson.M{
+		{"name": bson.M{"$eq": "a"}},
+		{"age": bson.M{"$gt": 18}},
+		{"name": bson.M{"$in": []string{"b", "c"}}},
+	}}, v2.Build())
+	assert.Equal(t, bson.M{"$or": []bson.M{{}, {"age": bson.M{"$gt": 18}}}}, base.Build())
+	assert.NotEqual(t, expected, base.Build())
+
+	// recorded errors and strict mode are cloned
+	safe := builder.NewSafe().Auto(1)
+	clone := safe.Clone().Auto(2)
+	assert.Len(t, safe.Errors(), 1)
+	assert.Len(t, clone.Errors(), 2)
+
+	// user values are copied
+	vals := bson.A{1, 2}
+	b := builder.New().AnyMap("tags", bson.M{"$in": vals})
+	clone = b.Clone()
+	vals[0] = 3
+	assert.Equal(t, bson.M{"tags": bson.M{"$in": bson.A{1, 2}}}, clone.Build())
+}
+
+func TestBuilder_BuildIdempotent(t *testing.T) {
+	b := builder.New().Str("name").Eq("a").Or().Arr("tags").ContainsAll("x", "y")
+	first := b.Build()
+	assert.Equal(t, first, b.Build())
+	assert.Equal(t, b.BuildD(), b.BuildD())
+
+	// the result doesn't share values with the builder
+	first["$or"].([]bson.M)[1]["tags"].(bson.M)["$all"].([]interface{})[0] = "z"
+	first["$or"].([]bson.M)[0]["name"] = "b"
+	assert.Equal(t, bson.M{"$or": []bson.M{
+		{"name": bson.M{"$eq": "a"}},
+		{"tags": bson.M{"$all": []interface{}{"x", "y"}}},
+	}}, b.Build())
+
+	// user values are copied once by BuildD as well
+	b = builder.New().Str("name").In("a", "b").AnyMap("meta", bson.M{"$in": bson.A{1}})
+	d := b.BuildD()
+	d[0].Value.(bson.D)[0].Value.([]string)[0] = "z"
+	d[1].Value.(bson.D)[0].Value.(bson.A)[0] = 2
+	assert.Equal(t, bson.M{
+		"name": bson.M{"$in": []string{"a", "b"}},
+		"meta": bson.M{"$in": bson.A{1}},
+	}, b.Build())
+}
@@ -0,0 +1,233 @@
+package builder
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// normalizeValue converts v to the type decoded by the driver, eg: int to int32 or int64,
+// time.Time to primitive.DateTime, structs and maps to bson.D.
+func normalizeValue(v interface{}) (interface{}, bool) {
+	data, err := bson.Marshal(bson.D{{Key: "v", Value: v}})
+	if err != nil {
+		return nil, false
+	}
+	var d bson.D
+	if err := bson.Unmarshal(data, &d); err != nil || len(d) != 1 {
+		return nil, false
+	}
+	return d[0].Value, true
+}
+
+// typeOrder returns the order of the type of a normalized value in the BSON comparison order:
+// MinKey, Null, Numbers, Symbol and String, Object, Array, BinData, ObjectId, Boolean, Date, Timestamp, Regex, MaxKey.
+func typeOrder(v interface{}) int {
+	switch v.(type) {
+	case primitive.MinKey:
+		return 1
+	case nil, primitive.Null, primitive.Undefined:
+		return 2
+	case int32, int64, float64, primitive.Decimal128:
+		return 3
+	case string, primitive.Symbol:
+		return 4
+	case bson.D:
+		return 5
+	case bson.A:
+		return 6
+	case primitive.Binary:
+		return 7
+	case primitive.ObjectID:
+		return 8
+	case bool:
+		return 9
+	case primitive.DateTime:
+		return 10
+	case primitive.Timestamp:
+		return 11
+	case primitive.Regex:
+		return 12
+	case primitive.MaxKey:
+		return 13
+	}
+	return 0
+}
+
+// sameTypeBracket reports whether a and b are compared by value in queries, eg: both are numbers.
+func sameTypeBracket(a, b interface{}) bool {
+	na, ok := normalizeValue(a)
+	if !ok {
+		return false
+	}
+	nb, ok := normalizeValue(b)
+	if !ok {
+		return false
+	}
+	return typeOrder(na) == typeOrder(nb)
+}
+
+// CompareValues compares a and b in the BSON comparison order used by sorting,
+// it returns -1 if a < b, 0 if a == b, and 1 if a > b.
+//
+// Values of different types are ordered by their types, eg: numbers are less than strings,
+// and numbers of different types are compared by their values.
+func CompareValues(a, b interface{}) int {
+	na, _ := normalizeValue(a)
+	nb, _ := normalizeValue(b)
+	return compareNormalized(na, nb)
+}
+
+// compareNormalized compares normalized values, see CompareValues.
+func compareNormalized(a, b interface{}) int {
+	oa, ob := typeOrder(a), typeOrder(b)
+	if oa != ob {
+		return compareInt(int64(oa), int64(ob))
+	}
+
+	switch a := a.(type) {
+	case int32, int64, float64, primitive.Decimal128:
+		return compareNumbers(a, b)
+	case string, primitive.Symbol:
+		return strings.Compare(stringOf(a), stringOf(b))
+	case bson.D:
+		bd := b.(bson.D)
+		for i := 0; i < len(a) && i < len(bd); i++ {
+			if c := compareInt(int64(typeOrder(a[i].Value)), int64(typeOrder(bd[i].Value))); c != 0 {
+				return c
+			}
+			if c := strings.Compare(a[i].Key, bd[i].Key); c != 0 {
+				return c
+			}
+			if c := compareNormalized(a[i].Value, bd[i].Value); c != 0 {
+				return c
+			}
+		}
+		return compareInt(int64(len(a)), int64(len(bd)))
+	case bson.A:
+		ba := b.(bson.A)
+		for i := 0; i < len(a) && i < len(ba); i++ {
+			if c := compareNormalized(a[i], ba[i]); c != 0 {
+				return c
+			}
+		}
+		return compareInt(int64(len(a)), int64(len(ba)))
+	case primitive.Binary:
+		bb := b.(primitive.Binary)
+		if c := compareInt(int64(len(a.Data)), int64(len(bb.Data))); c != 0 {
+			return c
+		}
+		if c := compareInt(int64(a.Subtype), int64(bb.Subtype)); c != 0 {
+			return c
+		}
+		return bytes.Compare(a.Data, bb.Data)
+	case primitive.ObjectID:
+		bo := b.(primitive.ObjectID)
+		return bytes.Compare(a[:], bo[:])
+	case bool:
+		bb := b.(bool)
+		switch {
+		case a == bb:
+			return 0
+		case !a:
+			return -1
+		}
+		return 1
+	case primitive.DateTime:
+		return compareInt(int64(a), int64(b.(primitive.DateTime)))
+	case primitive.Timestamp:
+		bt := b.(primitive.Timestamp)
+		if c := compareInt(int64(a.T), int64(bt.T)); c != 0 {
+			return c
+		}
+		return compareInt(int64(a.I), int64(bt.I))
+	case primitive.Regex:
+		br := b.(primitive.Regex)
+		if c := strings.Compare(a.Pattern, br.Pattern); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Options, br.Options)
+	}
+	return 0
+}
+
+// compareInt compares two integers.
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// stringOf returns the string of a string or a symbol.
+func stringOf(v interface{}) string {
+	if s, ok := v.(primitive.Symbol); ok {
+		return string(s)
+	}
+	return v.(string)
+}
+
+// compareNumbers compares numbers of different types, NaN is less than any other number.
+func compareNumbers(a, b interface{}) int {
+	ia, aInt := a.(int64)
+	if i, ok := a.(int32); ok {
+		ia, aInt = int64(i), true
+	}
+	ib, bInt := b.(int64)
+	if i, ok := b.(int32); ok {
+		ib, bInt = int64(i), true
+	}
+	if aInt && bInt {
+		return compareInt(ia, ib)
+	}
+
+	fa, aNaN := bigFloat(a)
+	fb, bNaN := bigFloat(b)
+	switch {
+	case aNaN && bNaN:
+		return 0
+	case aNaN:
+		return -1
+	case bNaN:
+		return 1
+	}
+	return fa.Cmp(fb)
+}
+
+// bigFloat converts a number to big.Float, and reports whether it's NaN.
+func bigFloat(v interface{}) (*big.Float, bool) {
+	switch v := v.(type) {
+	case int32:
+		return new(big.Float).SetInt64(int64(v)), false
+	case int64:
+		return new(big.Float).SetInt64(v), false
+	case float64:
+		if math.IsNaN(v) {
+			return nil, true
+		}
+		return new(big.Float).SetFloat64(v), false
+	case primitive.Decimal128:
+		s := v.String()
+		switch s {
+		case "NaN":
+			return nil, true
+		case "Infinity":
+			return new(big.Float).SetInf(false), false
+		case "-Infinity":
+			return new(big.Float).SetInf(true), false
+		}
+		f, _, err := big.ParseFloat(s, 10, 128, big.ToNearestEven)
+		if err != nil {
+			return nil, true
+		}
+		return f, false
+	}
+	return nil, true
+}
@@ -1,123 +1,175 @@
 package builder
 
 import (
-	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type cond struct {
 	// key is the root name for the current condtion, eq: {key: {$eq: ...}}.
 	key string
-	// TODO consider remove the m, populate the builder.curMap directly.
-	// m is the current map stores the value of condition.
-	m bson.M
 	// builder refers to the current builder.
 	builder *Builder
+	// negate indicates the following operators should be wrapped into `$not`.
+	negate bool
+	// not is the `$not` added by the condition, later negated operators are added into it.
+	not *Not
+	// kind is the kind of placeholders used by the condition, see Param.
+	kind ParamKind
 }
 
 func newCond(key string, builder *Builder) *cond {
 	return &cond{
 		key:     key,
-		m:       bson.M{},
 		builder: builder,
 	}
 }
 
-// addMapToBuilder adds baseCond.m to the referenced builder's map with key as baseCond.key.
-// If the same key is set again, it will try to merge two map.
-func (baseCond *cond) addMapToBuilder() {
-	var v interface{}
-	var ok bool
+// put adds `op: val` of baseCond.key to the builder's current condition.
+// If the same operator of the same key is added again, it will be overwritten.
+func (baseCond *cond) put(op string, val interface{}) *Builder {
+	return baseCond.builder.put(&Compare{Field: baseCond.key, Op: op, Value: baseCond.param(val)})
+}
+
+// Negate makes the following operators to be wrapped into `$not`, eg: {key: {$not: {$gt: 5}}}.
+//
+// Operators added to the same negated condition are wrapped into the same `$not`,
+// while `$not` of another condition on the same key is kept separately in `$and`.
+// Not(exp) is not the same, it adds a negated regex.
+func (baseCond *cond) Negate() *cond {
+	baseCond.negate = true
+	return baseCond
+}
+
+// set puts `op: val` to the builder, or puts it into `$not` if the condition is negated.
+func (baseCond *cond) set(op string, val interface{}) *Builder {
+	if !baseCond.negate {
+		return baseCond.put(op, val)
+	}
+	return baseCond.setNot(op, val)
+}
 
-	if v, ok = baseCond.builder.curMap[baseCond.key]; !ok {
-		baseCond.builder.curMap[baseCond.key] = baseCond.m
-		return
+// setNot puts `op: val` into the `$not` of the condition, the `$not` is added if it doesn't exist.
+func (baseCond *cond) setNot(op string, val interface{}) *Builder {
+	not := &Not{Field: baseCond.key}
+	if baseCond.not != nil {
+		not.Ops = baseCond.not.Ops
 	}
-	preMap := v.(bson.M)
+	not.Ops = setOp(not.Ops, &Compare{Field: baseCond.key, Op: op, Value: baseCond.param(val)})
+	old := baseCond.not
+	baseCond.not = not
+	return baseCond.builder.replaceNode(old, not)
+}
 
-	for k, v := range baseCond.m {
-		preMap[k] = v
+// setOp returns a copy of ops with c added, the same operator will be replaced in place.
+func setOp(ops []*Compare, c *Compare) []*Compare {
+	res := append([]*Compare{}, ops...)
+	for i, op := range res {
+		if op.Op == c.Op {
+			res[i] = c
+			return res
+		}
 	}
+	return append(res, c)
 }
 
-// Eq adds `$Eq: val` to the baseCond.m
+// Eq adds `$Eq: val` to the builder
 func (baseCond *cond) Eq(val interface{}) *Builder {
-	baseCond.m[_eq] = val
-	baseCond.addMapToBuilder()
-	return baseCond.builder
+	return baseCond.set(_eq, val)
 }
 
-// Ne adds `$Ne: val` to the baseCond.m
+// Ne adds `$Ne: val` to the builder
 func (baseCond *cond) Ne(val interface{}) *Builder {
-	baseCond.m[_ne] = val
-	baseCond.addMapToBuilder()
-	return baseCond.builder
+	return baseCond.set(_ne, val)
 }
 
-// Lt adds `$Lt: val` to the baseCond.m
+// Lt adds `$Lt: val` to the builder
 func (baseCond *cond) Lt(val interface{}) *Builder {
-	baseCond.m[_lt] = val
-	baseCond.addMapToBuilder()
-	return baseCond.builder
+	return baseCond.set(_lt, val)
 }
 
-// Lte adds `$Lte: val` to the baseCond.m
+// Lte adds `$Lte: val` to the builder
 func (baseCond *cond) Lte(val interface{}) *Builder {
-	baseCond.m[_lte] = val
-	baseCond.addMapToBuilder()
-	return baseCond.builder
+	return baseCond.set(_lte, val)
 }
 
-// gt adds `$gt: val` to the baseCond.m
+// gt adds `$gt: val` to the builder
 func (baseCond *cond) gt(val interface{}) *Builder {
-	baseCond.m[_gt] = val
-	baseCond.addMapToBuilder()
-	return baseCond.builder
+	return baseCond.set(_gt, val)
 }
 
-// Gte adds `$Gte: val` to the baseCond.m
+// Gte adds `$Gte: val` to the builder
 func (baseCond *cond) Gte(val interface{}) *Builder {
-	baseCond.m[_gte] = val
-	baseCond.addMapToBuilder()
-	return baseCond.builder
+	return baseCond.set(_gte, val)
 }
 
-// Regex adds `$Regex: exp, $options: ""` to the baseCond.m
+// Regex adds `$Regex: exp, $options: ""` to the builder
 func (baseCond *cond) Regex(exp string) *Builder {
 	baseCond.RegexWithOpt(exp, "")
 	return baseCond.builder
 }
 
-// RegexWithOpt adds `$regex: exp, $options: opt` to the baseCond.m
+// RegexWithOpt adds `$regex: exp, $options: opt` to the builder
+//
+// If the condition is negated, `$not: /exp/opt` is added instead since `$not` doesn't accept `$regex`.
 func (baseCond *cond) RegexWithOpt(exp string, opt string) *Builder {
-	baseCond.m[_regex] = primitive.Regex{Pattern: exp, Options: opt}
-	baseCond.addMapToBuilder()
-	return baseCond.builder
+	if baseCond.negate {
+		return baseCond.NotWithOpt(exp, opt)
+	}
+	return baseCond.set(_regex, primitive.Regex{Pattern: exp, Options: opt})
 }
 
-// Not adds `$not: exp, $options: ""` to the baseCond.m
+// Not adds `$not: /exp/` to the builder, see NotWithOpt
 func (baseCond *cond) Not(exp string) *Builder {
 	baseCond.NotWithOpt(exp, "")
 	return baseCond.builder
 }
 
-// NotWithOpt adds `$not: exp, $options: opt` to the baseCond.m
+// NotWithOpt adds `$not: /exp/opt` to the builder
+//
+// The regex is put into the same `$not` as other negated operators of the condition,
+// eg: {key: {$not: {$regex: /exp/, $eq: val}}}.
 func (baseCond *cond) NotWithOpt(exp string, opt string) *Builder {
-	baseCond.m[_not] = primitive.Regex{Pattern: exp, Options: opt}
-	baseCond.addMapToBuilder()
-	return baseCond.builder
+	return baseCond.setNot(_regex, primitive.Regex{Pattern: exp, Options: opt})
 }
 
-// In adds `$In: vals` to the baseCond.m
+// In adds `$In: vals` to the builder
 func (baseCond *cond) In(vals interface{}) *Builder {
-	baseCond.m[_in] = vals
-	baseCond.addMapToBuilder()
-	return baseCond.builder
+	return baseCond.set(_in, vals)
 }
 
-// Nin adds `$Nin: vals` to the baseCond.m
+// Nin adds `$Nin: vals` to the builder
 func (baseCond *cond) Nin(vals interface{}) *Builder {
-	baseCond.m[_nin] = vals
-	baseCond.addMapToBuilder()
-	return baseCond.builder
+	return baseCond.set(_nin, vals)
+}
+
+// Exists adds `$exists: exists` to the builder
+func (baseCond *cond) Exists(exists bool) *Builder {
+	return baseCond.set(_exists, exists)
+}
+
+// Type adds `$type: t` to the builder, multiple types will be added as an array.
+func (baseCond *cond) Type(types ...bsontype.Type) *Builder {
+	codes := make([]interface{}, 0, len(types))
+	for _, t := range types {
+		codes = append(codes, int32(t))
+	}
+	return baseCond.setTypes(codes)
+}
+
+// TypeAlias is like Type but uses type aliases such as "string", "number" and "objectId".
+func (baseCond *cond) TypeAlias(aliases ...string) *Builder {
+	names := make([]interface{}, 0, len(aliases))
+	for _, a := range aliases {
+		names = append(names, a)
+	}
+	return baseCond.setTypes(names)
+}
+
+// setTypes adds `$type` with single value or array.
+func (baseCond *cond) setTypes(types []interface{}) *Builder {
+	if len(types) == 1 {
+		return baseCond.set(_type, types[0])
+	}
+	return baseCond.set(_type, types)
 }
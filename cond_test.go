@@ -0,0 +1,102 @@
+package builder_test
+
+import (
+	"testing"
+
+	builder "github.com/JsyTech/mongo-filter-builder"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCond_Negate(t *testing.T) {
+	b := builder.New().Num("age").Negate().Gt(5).Build()
+	c := bson.M{"age": bson.M{"$not": bson.M{"$gt": 5}}}
+	assert.Equal(t, c, b)
+
+	b = builder.New().Str("tags").Negate().In("a", "b").Build()
+	c = bson.M{"tags": bson.M{"$not": bson.M{"$in": []string{"a", "b"}}}}
+	assert.Equal(t, c, b)
+
+	// merges with other operators on the same key
+	b = builder.New().
+		Num("age").Gte(1).
+		Num("age").Negate().Between(10, 20).
+		Build()
+	c = bson.M{"age": bson.M{
+		"$gte": 1,
+		"$not": bson.M{"$gte": 10, "$lte": 20},
+	}}
+	assert.Equal(t, c, b)
+
+	// regex can't be placed in $not as $regex
+	b = builder.New().Str("name").Negate().RegexWithOpt("^a", "i").Build()
+	c = bson.M{"name": bson.M{"$not": primitive.Regex{Pattern: "^a", Options: "i"}}}
+	assert.Equal(t, c, b)
+
+	// negated regex and other negated operators of the same condition share the $not
+	name := builder.New().Str("name").Negate()
+	name.Regex("^x")
+	b = name.Eq("y").Build()
+	c = bson.M{"name": bson.M{"$not": bson.M{"$regex": primitive.Regex{Pattern: "^x"}, "$eq": "y"}}}
+	assert.Equal(t, c, b)
+
+	// $not of other conditions on the same key are kept separately
+	b = builder.New().
+		Str("name").Negate().Regex("^x").
+		Str("name").Negate().Eq("y").
+		Build()
+	c = bson.M{
+		"name": bson.M{"$not": primitive.Regex{Pattern: "^x"}},
+		"$and": []bson.M{{"name": bson.M{"$not": bson.M{"$eq": "y"}}}},
+	}
+	assert.Equal(t, c, b)
+
+	b = builder.New().Str("name").Not("^x").Str("name").Not("^y").Build()
+	c = bson.M{
+		"name": bson.M{"$not": primitive.Regex{Pattern: "^x"}},
+		"$and": []bson.M{{"name": bson.M{"$not": primitive.Regex{Pattern: "^y"}}}},
+	}
+	assert.Equal(t, c, b)
+
+	b = builder.New().Any("flag").Negate().Eq(true).Build()
+	c = bson.M{"flag": bson.M{"$not": bson.M{"$eq": true}}}
+	assert.Equal(t, c, b)
+
+	// Not(exp) adds a negated regex on every condition
+	b = builder.New().Num("code").Not("^1").Build()
+	c = bson.M{"code": bson.M{"$not": primitive.Regex{Pattern: "^1"}}}
+	assert.Equal(t, c, b)
+
+	id := primitive.NewObjectID()
+	b = builder.New().Oid().Negate().Eq(id.Hex()).Build()
+	c = bson.M{"_id": bson.M{"$not": bson.M{"$eq": id}}}
+	assert.Equal(t, c, b)
+}
+
+func TestCond_Field(t *testing.T) {
+	b := builder.New().Field("deleted_at").IsMissing().Build()
+	c := bson.M{"deleted_at": bson.M{"$exists": false}}
+	assert.Equal(t, c, b)
+
+	b = builder.New().Field("deleted_at").IsNull().Build()
+	c = bson.M{"deleted_at": bson.M{"$type": int32(10)}}
+	assert.Equal(t, c, b)
+
+	b = builder.New().Field("deleted_at").NotNull().Build()
+	c = bson.M{"deleted_at": bson.M{"$ne": nil}}
+	assert.Equal(t, c, b)
+
+	b = builder.New().Field("deleted_at").IsNullOrMissing().Build()
+	c = bson.M{"deleted_at": bson.M{"$eq": nil}}
+	assert.Equal(t, c, b)
+
+	b = builder.New().Field("price").Exists(true).Field("price").Type(bsontype.String, bsontype.Null).Build()
+	c = bson.M{"price": bson.M{"$exists": true, "$type": []interface{}{int32(2), int32(10)}}}
+	assert.Equal(t, c, b)
+
+	b = builder.New().Num("price").Negate().TypeAlias("number").Build()
+	c = bson.M{"price": bson.M{"$not": bson.M{"$type": "number"}}}
+	assert.Equal(t, c, b)
+}
@@ -18,10 +18,18 @@ func newDateCond(key string, builder *Builder, format ...string) *dateCond {
 	if len(format) != 0 {
 		defaultFormat = format[0]
 	}
-	return &dateCond{
+	c := &dateCond{
 		cond:          newCond(key, builder),
 		defaultFormat: defaultFormat,
 	}
+	c.kind = DateParam
+	return c
+}
+
+// Negate makes the following operators to be wrapped into `$not`, eg: Date("created_at").Negate().Between(min, max).
+func (c *dateCond) Negate() *dateCond {
+	c.cond.Negate()
+	return c
 }
 
 func (c *dateCond) Eq(val time.Time) *Builder {
@@ -29,7 +37,10 @@ func (c *dateCond) Eq(val time.Time) *Builder {
 }
 
 func (c *dateCond) EqStr(val string, format ...string) *Builder {
-	t := c.mustParse(val, format...)
+	t, ok := c.parse(_eq, val, format...)
+	if !ok {
+		return c.builder
+	}
 	return c.Eq(t)
 }
 
@@ -38,7 +49,10 @@ func (c *dateCond) Ne(val time.Time) *Builder {
 }
 
 func (c *dateCond) NeStr(val string, format ...string) *Builder {
-	t := c.mustParse(val, format...)
+	t, ok := c.parse(_ne, val, format...)
+	if !ok {
+		return c.builder
+	}
 	return c.Ne(t)
 }
 
@@ -47,7 +61,10 @@ func (c *dateCond) Lt(val time.Time) *Builder {
 }
 
 func (c *dateCond) LtStr(val string, format ...string) *Builder {
-	t := c.mustParse(val, format...)
+	t, ok := c.parse(_lt, val, format...)
+	if !ok {
+		return c.builder
+	}
 	return c.Lt(t)
 }
 
@@ -56,7 +73,10 @@ func (c *dateCond) Lte(val time.Time) *Builder {
 }
 
 func (c *dateCond) LteStr(val string, format ...string) *Builder {
-	t := c.mustParse(val, format...)
+	t, ok := c.parse(_lte, val, format...)
+	if !ok {
+		return c.builder
+	}
 	return c.Lte(t)
 }
 
@@ -65,7 +85,10 @@ func (c *dateCond) Gt(val time.Time) *Builder {
 }
 
 func (c *dateCond) GtStr(val string, format ...string) *Builder {
-	t := c.mustParse(val, format...)
+	t, ok := c.parse(_gt, val, format...)
+	if !ok {
+		return c.builder
+	}
 	return c.Gt(t)
 }
 
@@ -74,7 +97,10 @@ func (c *dateCond) Gte(val time.Time) *Builder {
 }
 
 func (c *dateCond) GteStr(val string, format ...string) *Builder {
-	t := c.mustParse(val, format...)
+	t, ok := c.parse(_gte, val, format...)
+	if !ok {
+		return c.builder
+	}
 	return c.Gte(t)
 }
 
@@ -88,9 +114,52 @@ func (c *dateCond) Between(min, max time.Time) *Builder {
 	return c.cond.Lte(max)
 }
 
+// EqParam adds `$eq: Param(name)` to the builder, see Template.
+func (c *dateCond) EqParam(name string) *Builder {
+	return c.cond.Eq(Param(name))
+}
+
+// NeParam adds `$ne: Param(name)` to the builder, see Template.
+func (c *dateCond) NeParam(name string) *Builder {
+	return c.cond.Ne(Param(name))
+}
+
+// LtParam adds `$lt: Param(name)` to the builder, see Template.
+func (c *dateCond) LtParam(name string) *Builder {
+	return c.cond.Lt(Param(name))
+}
+
+// LteParam adds `$lte: Param(name)` to the builder, see Template.
+func (c *dateCond) LteParam(name string) *Builder {
+	return c.cond.Lte(Param(name))
+}
+
+// GtParam adds `$gt: Param(name)` to the builder, see Template.
+func (c *dateCond) GtParam(name string) *Builder {
+	return c.cond.gt(Param(name))
+}
+
+// GteParam adds `$gte: Param(name)` to the builder, see Template.
+func (c *dateCond) GteParam(name string) *Builder {
+	return c.cond.Gte(Param(name))
+}
+
+// BetweenParam is like Between with parameters min and max, see Template.
+func (c *dateCond) BetweenParam(min, max string) *Builder {
+	c.builder.RemoveCond(c.key, false)
+	c.cond.Gte(Param(min))
+	return c.cond.Lte(Param(max))
+}
+
 func (c *dateCond) BetweenStr(min, max string, format ...string) *Builder {
-	minT := c.mustParse(min, format...)
-	maxT := c.mustParse(max, format...)
+	minT, ok := c.parse("Between", min, format...)
+	if !ok {
+		return c.builder
+	}
+	maxT, ok := c.parse("Between", max, format...)
+	if !ok {
+		return c.builder
+	}
 	return c.Between(minT, maxT)
 }
 
@@ -102,8 +171,8 @@ func (c *dateCond) RangeStr(rg []string, format ...string) *Builder {
 	return c.BetweenStr(rg[0], rg[1], format...)
 }
 
-// mustParse parses time from string with format.
-func (c *dateCond) mustParse(timeStr string, format ...string) time.Time {
+// parse parses time from string with format, ok is false if the condition of op fails.
+func (c *dateCond) parse(op string, timeStr string, format ...string) (t time.Time, ok bool) {
 	f := c.defaultFormat
 	if len(format) != 0 {
 		f = format[0]
@@ -111,7 +180,8 @@ func (c *dateCond) mustParse(timeStr string, format ...string) time.Time {
 
 	t, err := time.Parse(f, timeStr)
 	if err != nil {
-		panic(fmt.Errorf("filterBuilder: failed to parse time from string: %s with format: %s, err: %v", timeStr, f, err))
+		c.builder.fail(c.key, op, fmt.Errorf("failed to parse time from string: %s with format: %s, err: %v", timeStr, f, err))
+		return t, false
 	}
-	return t
+	return t, true
 }
@@ -0,0 +1,80 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var errNotStruct = errors.New("the given value is not struct")
+
+// CondError records a condition failed to be built.
+type CondError struct {
+	// Key is the key of the condition, it's empty for builder level operations such as Auto.
+	Key string
+	// Op is the operator or the method of the condition.
+	Op string
+	// Cause is the reason of the failure.
+	Cause error
+}
+
+func (e *CondError) Error() string {
+	return fmt.Sprintf("filterBuilder: failed to build %s on key %q: %v", e.Op, e.Key, e.Cause)
+}
+
+func (e *CondError) Unwrap() error {
+	return e.Cause
+}
+
+// NewSafe constructs a new Builder in non-strict mode, see Builder.Strict.
+func NewSafe() *Builder {
+	return New().Strict(false)
+}
+
+// Strict sets the strict mode of the builder, the builder is in strict mode by default.
+//
+// In strict mode, a failed condition leads to a panic.
+// Otherwise the failed condition is skipped and recorded,
+// all recorded errors can be checked with Errors or BuildE.
+func (b *Builder) Strict(strict bool) *Builder {
+	b.strict = strict
+	return b
+}
+
+// Errors returns all recorded errors of failed conditions.
+func (b *Builder) Errors() []error {
+	return b.errs
+}
+
+// BuildE builds final filter like Build, and returns all recorded errors
+// and errors of unbound placeholders joined as one.
+// Each of the joined errors is a *CondError.
+//
+// The filter is nil if there is any error, as the filter without failed conditions
+// may match more documents than expected. Use Build to get it anyway.
+func (b *Builder) BuildE() (bson.M, error) {
+	d, unbound := b.build()
+	errs := append(b.errs[:len(b.errs):len(b.errs)], unbound...)
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return toM(d), nil
+}
+
+// fail records the failed condition in non-strict mode, or panics in strict mode.
+func (b *Builder) fail(key, op string, cause error) {
+	err := &CondError{Key: key, Op: op, Cause: cause}
+	if b.strict {
+		panic(err)
+	}
+	b.errs = append(b.errs, err)
+}
+
+// sub constructs a builder for building nested conditions, which inherits the strict mode.
+func (b *Builder) sub(fn func(*Builder)) *Builder {
+	sub := New().Strict(b.strict)
+	fn(sub)
+	b.errs = append(b.errs, sub.errs...)
+	return sub
+}
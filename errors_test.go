@@ -0,0 +1,67 @@
+package builder_test
+
+import (
+	"errors"
+	"testing"
+
+	builder "github.com/JsyTech/mongo-filter-builder"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestBuilder_BuildE(t *testing.T) {
+	b := builder.NewSafe().
+		Str("name").Eq("a").
+		Date("created_at").GteStr("not a time").
+		Oid().Eq("bad hex").
+		Num("id").Mod(0, 1).
+		Geo("loc").Near(builder.NewPoint(200, 0), 0, 0)
+	b.Arr("items").ElemMatch(func(e *builder.Builder) {
+		e.Date("at").LtStr("bad")
+	})
+	b.Auto(1)
+
+	// the filter without failed conditions is only returned by Build
+	f, err := b.BuildE()
+	assert.Nil(t, f)
+	assert.Error(t, err)
+	assert.Len(t, b.Errors(), 6)
+	assert.Equal(t, bson.M{"name": bson.M{"$eq": "a"}}, b.Build())
+
+	var condErr *builder.CondError
+	assert.True(t, errors.As(b.Errors()[0], &condErr))
+	assert.Equal(t, "created_at", condErr.Key)
+	assert.Equal(t, "$gte", condErr.Op)
+	assert.True(t, errors.As(b.Errors()[4], &condErr))
+	assert.Equal(t, "at", condErr.Key)
+	assert.Equal(t, "$lt", condErr.Op)
+
+	// $elemMatch and groups are skipped as a whole instead of matching other documents
+	b = builder.NewSafe().Arr("items").ElemMatch(func(e *builder.Builder) {
+		e.Str("sku").Eq("x").Date("at").LtStr("bad")
+	})
+	assert.Equal(t, bson.M{}, b.Build())
+	assert.Len(t, b.Errors(), 1)
+
+	id := primitive.NewObjectID().Hex()
+	groups := []func(*builder.Builder, func(*builder.Builder)) *builder.Builder{
+		(*builder.Builder).AndGroup, (*builder.Builder).OrGroup, (*builder.Builder).NorGroup,
+	}
+	for _, group := range groups {
+		b = group(builder.NewSafe().Str("name").Eq("a"), func(g *builder.Builder) {
+			g.Oid("owner").Eq(id).Or().Oid("team").Eq("bad")
+		})
+		assert.Equal(t, bson.M{"name": bson.M{"$eq": "a"}}, b.Build())
+		assert.Len(t, b.Errors(), 1)
+	}
+
+	f, err = builder.NewSafe().Str("name").Eq("a").BuildE()
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{"name": bson.M{"$eq": "a"}}, f)
+
+	// failed conditions lead to a panic in strict mode
+	assert.Panics(t, func() { builder.New().Oid().Eq("bad hex") })
+	assert.Panics(t, func() { builder.New().Strict(false).Strict(true).Date("at").EqStr("bad") })
+	assert.NotPanics(t, func() { builder.New().Strict(false).Date("at").EqStr("bad") })
+}
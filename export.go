@@ -0,0 +1,225 @@
+package builder
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ToExtJSON renders the built filter as MongoDB Extended JSON v2,
+// in canonical mode if canonical is true, otherwise in relaxed mode.
+func (b *Builder) ToExtJSON(canonical bool) (string, error) {
+	data, err := bson.MarshalExtJSON(b.BuildD(), canonical, false)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// ToShell renders the built filter in mongosh syntax, eg:
+//
+//	{name: /^a/i, created_at: {$gte: ISODate("2021-01-01T00:00:00.000Z")}, _id: ObjectId("...")}
+func (b *Builder) ToShell() string {
+	var buf bytes.Buffer
+	writeShell(&buf, b.BuildD())
+	return buf.String()
+}
+
+// shellKeyRe matches keys which need no quotes in mongosh.
+var shellKeyRe = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// writeShell writes val in mongosh syntax to buf.
+func writeShell(buf *bytes.Buffer, val interface{}) {
+	switch v := val.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bson.D:
+		buf.WriteByte('{')
+		for i, e := range v {
+			if i != 0 {
+				buf.WriteString(", ")
+			}
+			writeShellKey(buf, e.Key)
+			buf.WriteString(": ")
+			writeShell(buf, e.Value)
+		}
+		buf.WriteByte('}')
+	case bson.M:
+		writeShell(buf, sortedDoc(v))
+	case string:
+		writeShellString(buf, v)
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case int8, int16, int32, uint8, uint16:
+		fmt.Fprintf(buf, "%d", v)
+	case int:
+		writeShellInt(buf, int64(v))
+	case int64:
+		fmt.Fprintf(buf, "NumberLong(\"%d\")", v)
+	case uint, uint32, uint64:
+		fmt.Fprintf(buf, "NumberLong(\"%d\")", v)
+	case float32:
+		writeShellFloat(buf, float64(v))
+	case float64:
+		writeShellFloat(buf, v)
+	case time.Time:
+		fmt.Fprintf(buf, "ISODate(\"%s\")", v.UTC().Format("2006-01-02T15:04:05.000Z"))
+	case primitive.DateTime:
+		writeShell(buf, v.Time())
+	case primitive.ObjectID:
+		fmt.Fprintf(buf, "ObjectId(\"%s\")", v.Hex())
+	case primitive.Regex:
+		pattern := v.Pattern
+		if pattern == "" {
+			pattern = "(?:)"
+		}
+		fmt.Fprintf(buf, "/%s/%s", escapeSlash(pattern), v.Options)
+	case primitive.Binary:
+		fmt.Fprintf(buf, "BinData(%d, \"%s\")", v.Subtype, base64.StdEncoding.EncodeToString(v.Data))
+	case []byte:
+		writeShell(buf, primitive.Binary{Data: v})
+	case primitive.Decimal128:
+		fmt.Fprintf(buf, "NumberDecimal(\"%s\")", v.String())
+	case primitive.Timestamp:
+		fmt.Fprintf(buf, "Timestamp(%d, %d)", v.T, v.I)
+	case primitive.MinKey:
+		buf.WriteString("MinKey()")
+	case primitive.MaxKey:
+		buf.WriteString("MaxKey()")
+	case primitive.Null:
+		buf.WriteString("null")
+	case primitive.Undefined:
+		buf.WriteString("undefined")
+	default:
+		writeShellReflect(buf, val)
+	}
+}
+
+// writeShellReflect writes slices, maps and structs in mongosh syntax.
+func writeShellReflect(buf *bytes.Buffer, val interface{}) {
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			buf.WriteString("null")
+			return
+		}
+		writeShell(buf, rv.Elem().Interface())
+		return
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			buf.WriteString("null")
+			return
+		}
+		buf.WriteByte('[')
+		for i := 0; i < rv.Len(); i++ {
+			if i != 0 {
+				buf.WriteString(", ")
+			}
+			writeShell(buf, rv.Index(i).Interface())
+		}
+		buf.WriteByte(']')
+		return
+	case reflect.Map:
+		if rv.Type().Key().Kind() == reflect.String {
+			keys := make([]string, 0, rv.Len())
+			for _, k := range rv.MapKeys() {
+				keys = append(keys, k.String())
+			}
+			sort.Strings(keys)
+			d := make(bson.D, 0, len(keys))
+			for _, k := range keys {
+				d = append(d, bson.E{Key: k, Value: rv.MapIndex(reflect.ValueOf(k).Convert(rv.Type().Key())).Interface()})
+			}
+			writeShell(buf, d)
+			return
+		}
+	case reflect.String:
+		writeShellString(buf, rv.String())
+		return
+	case reflect.Bool:
+		buf.WriteString(strconv.FormatBool(rv.Bool()))
+		return
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		writeShell(buf, rv.Convert(reflect.TypeOf(int64(0))).Interface())
+		return
+	case reflect.Float32, reflect.Float64:
+		writeShellFloat(buf, rv.Float())
+		return
+	}
+
+	// structs and others are rendered as how they are stored
+	if data, err := bson.Marshal(bson.D{{Key: "v", Value: val}}); err == nil {
+		var d bson.D
+		if err := bson.Unmarshal(data, &d); err == nil && len(d) == 1 {
+			writeShell(buf, d[0].Value)
+			return
+		}
+	}
+	fmt.Fprintf(buf, "%v", val)
+}
+
+// writeShellKey writes key, which is quoted if necessary.
+func writeShellKey(buf *bytes.Buffer, key string) {
+	if shellKeyRe.MatchString(key) {
+		buf.WriteString(key)
+		return
+	}
+	writeShellString(buf, key)
+}
+
+// writeShellString writes s as a JSON string.
+func writeShellString(buf *bytes.Buffer, s string) {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	buf.Truncate(buf.Len() - 1) // trailing newline of Encode
+}
+
+// writeShellInt writes i as a 32-bit integer if it fits, otherwise as NumberLong.
+func writeShellInt(buf *bytes.Buffer, i int64) {
+	if i >= math.MinInt32 && i <= math.MaxInt32 {
+		fmt.Fprintf(buf, "%d", i)
+		return
+	}
+	fmt.Fprintf(buf, "NumberLong(\"%d\")", i)
+}
+
+// writeShellFloat writes f as a JavaScript number.
+func writeShellFloat(buf *bytes.Buffer, f float64) {
+	switch {
+	case math.IsNaN(f):
+		buf.WriteString("NaN")
+	case math.IsInf(f, 1):
+		buf.WriteString("Infinity")
+	case math.IsInf(f, -1):
+		buf.WriteString("-Infinity")
+	default:
+		buf.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+	}
+}
+
+// escapeSlash escapes unescaped "/" in a regex pattern.
+func escapeSlash(pattern string) string {
+	var sb strings.Builder
+	escaped := false
+	for _, r := range pattern {
+		if r == '/' && !escaped {
+			sb.WriteByte('\\')
+		}
+		escaped = r == '\\' && !escaped
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
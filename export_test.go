@@ -0,0 +1,57 @@
+package builder_test
+
+import (
+	"testing"
+	"time"
+
+	builder "github.com/JsyTech/mongo-filter-builder"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestBuilder_ToShell(t *testing.T) {
+	id, _ := primitive.ObjectIDFromHex("5f1d7f3b9d2b4a0001a1b2c3")
+	at := time.Date(2021, 1, 2, 3, 4, 5, 6000000, time.UTC)
+
+	b := builder.New().
+		Str("name").RegexWithOpt("^a/b", "i").
+		Date("created_at").Gte(at).
+		Oid().Eq(id.Hex()).
+		Num("age").In([]int{1, 2}).
+		Num("big").Gt(int64(1) << 40).
+		Num("score").Lt(0.5).
+		Field("deleted_at").IsNullOrMissing().
+		Str("tags.0").Negate().Eq(`say "hi"`)
+	assert.Equal(t,
+		`{name: {$regex: /^a\/b/i}, created_at: {$gte: ISODate("2021-01-02T03:04:05.006Z")}, `+
+			`_id: {$eq: ObjectId("5f1d7f3b9d2b4a0001a1b2c3")}, age: {$in: [1, 2]}, big: {$gt: NumberLong("1099511627776")}, `+
+			`score: {$lt: 0.5}, deleted_at: {$eq: null}, "tags.0": {$not: {$eq: "say \"hi\""}}}`,
+		b.ToShell())
+
+	b = builder.New().Str("name").Eq("a").Or().Str("name").NotLike("b")
+	assert.Equal(t, `{$or: [{name: {$eq: "a"}}, {name: {$not: /b/}}]}`, b.ToShell())
+}
+
+func TestBuilder_ToExtJSON(t *testing.T) {
+	id, _ := primitive.ObjectIDFromHex("5f1d7f3b9d2b4a0001a1b2c3")
+	at := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	b := builder.New().
+		Oid().Eq(id.Hex()).
+		Date("created_at").Gte(at).
+		Num("age").Gt(int32(18)).
+		Str("name").RegexWithOpt("^a", "i")
+
+	s, err := b.ToExtJSON(true)
+	assert.NoError(t, err)
+	assert.Equal(t,
+		`{"_id":{"$eq":{"$oid":"5f1d7f3b9d2b4a0001a1b2c3"}},"created_at":{"$gte":{"$date":{"$numberLong":"1609556645000"}}},`+
+			`"age":{"$gt":{"$numberInt":"18"}},"name":{"$regex":{"$regularExpression":{"pattern":"^a","options":"i"}}}}`,
+		s)
+
+	s, err = b.ToExtJSON(false)
+	assert.NoError(t, err)
+	assert.Equal(t,
+		`{"_id":{"$eq":{"$oid":"5f1d7f3b9d2b4a0001a1b2c3"}},"created_at":{"$gte":{"$date":"2021-01-02T03:04:05Z"}},`+
+			`"age":{"$gt":18},"name":{"$regex":{"$regularExpression":{"pattern":"^a","options":"i"}}}}`,
+		s)
+}
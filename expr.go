@@ -0,0 +1,150 @@
+package builder
+
+import (
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Expression represents an aggregation expression used in `$expr`, eg:
+//
+//	builder.Field("spent").Gt(builder.Field("budget"))
+//
+// Operands of expressions can be either Expression or literal values.
+type Expression struct {
+	v interface{}
+}
+
+// Field returns an expression refers to the value of the field path, eg: Field("spent") => "$spent".
+func Field(path string) Expression {
+	return Expression{"$" + path}
+}
+
+// Lit returns a literal expression, eg: Lit("$price") => {$literal: "$price"}.
+func Lit(val interface{}) Expression {
+	return Expression{bson.M{_literal: val}}
+}
+
+// Value returns the raw value of the expression.
+func (e Expression) Value() interface{} {
+	return e.v
+}
+
+// Eq returns `{$eq: [e, other]}`.
+func (e Expression) Eq(other interface{}) Expression {
+	return e.op(_eq, other)
+}
+
+// Ne returns `{$ne: [e, other]}`.
+func (e Expression) Ne(other interface{}) Expression {
+	return e.op(_ne, other)
+}
+
+// Gt returns `{$gt: [e, other]}`.
+func (e Expression) Gt(other interface{}) Expression {
+	return e.op(_gt, other)
+}
+
+// Gte returns `{$gte: [e, other]}`.
+func (e Expression) Gte(other interface{}) Expression {
+	return e.op(_gte, other)
+}
+
+// Lt returns `{$lt: [e, other]}`.
+func (e Expression) Lt(other interface{}) Expression {
+	return e.op(_lt, other)
+}
+
+// Lte returns `{$lte: [e, other]}`.
+func (e Expression) Lte(other interface{}) Expression {
+	return e.op(_lte, other)
+}
+
+// And returns `{$and: [e, others...]}`.
+func (e Expression) And(others ...Expression) Expression {
+	return e.op(_and, exprsToArgs(others)...)
+}
+
+// Or returns `{$or: [e, others...]}`.
+func (e Expression) Or(others ...Expression) Expression {
+	return e.op(_or, exprsToArgs(others)...)
+}
+
+// Add returns `{$add: [e, others...]}`.
+func (e Expression) Add(others ...interface{}) Expression {
+	return e.op(_add, others...)
+}
+
+// Subtract returns `{$subtract: [e, other]}`.
+func (e Expression) Subtract(other interface{}) Expression {
+	return e.op(_subtract, other)
+}
+
+// Multiply returns `{$multiply: [e, others...]}`.
+func (e Expression) Multiply(others ...interface{}) Expression {
+	return e.op(_multiply, others...)
+}
+
+// Divide returns `{$divide: [e, other]}`.
+func (e Expression) Divide(other interface{}) Expression {
+	return e.op(_divide, other)
+}
+
+// StrLenCP returns `{$strLenCP: e}`.
+func (e Expression) StrLenCP() Expression {
+	return Expression{bson.M{_strLenCP: e.v}}
+}
+
+// Size returns `{$size: e}`.
+func (e Expression) Size() Expression {
+	return Expression{bson.M{_size: e.v}}
+}
+
+// DateDiff returns `{$dateDiff: {startDate: e, endDate: end, unit: unit}}`,
+// unit can be one of "year", "quarter", "week", "month", "day", "hour", "minute", "second" and "millisecond".
+func (e Expression) DateDiff(end interface{}, unit string) Expression {
+	return Expression{bson.M{_dateDiff: bson.D{
+		{Key: "startDate", Value: e.v},
+		{Key: "endDate", Value: exprValue(end)},
+		{Key: "unit", Value: unit},
+	}}}
+}
+
+// op returns `{op: [e, others...]}`.
+func (e Expression) op(op string, others ...interface{}) Expression {
+	args := make([]interface{}, 0, len(others)+1)
+	args = append(args, e.v)
+	for _, o := range others {
+		args = append(args, exprValue(o))
+	}
+	return Expression{bson.M{op: args}}
+}
+
+// exprValue converts an operand to the value of expression.
+// Strings start with "$" are wrapped into `$literal` to avoid being treated as field paths.
+func exprValue(val interface{}) interface{} {
+	switch v := val.(type) {
+	case Expression:
+		return v.v
+	case string:
+		if strings.HasPrefix(v, "$") {
+			return bson.M{_literal: v}
+		}
+	}
+	return val
+}
+
+// exprsToArgs converts expressions to operands.
+func exprsToArgs(exprs []Expression) []interface{} {
+	args := make([]interface{}, 0, len(exprs))
+	for _, e := range exprs {
+		args = append(args, e)
+	}
+	return args
+}
+
+// Expr adds `$expr: e` to the current condition.
+// If `$expr` has been set already, the expression will be appended to `$and`.
+func (b *Builder) Expr(e Expression) *Builder {
+	return b.add("", _expr, &Expr{Value: e.v})
+}
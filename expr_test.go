@@ -0,0 +1,58 @@
+package builder_test
+
+import (
+	"testing"
+
+	builder "github.com/JsyTech/mongo-filter-builder"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestBuilder_Expr(t *testing.T) {
+	b := builder.New().Expr(builder.Field("spent").Gt(builder.Field("budget"))).Build()
+	c := bson.M{"$expr": bson.M{"$gt": []interface{}{"$spent", "$budget"}}}
+	assert.Equal(t, c, b)
+
+	// composes with regular conditions, multiple $expr are ANDed
+	b = builder.New().
+		Str("status").Eq("active").
+		Expr(builder.Field("updated_at").Gt(builder.Field("created_at"))).
+		Expr(builder.Field("price").Multiply(builder.Field("qty")).Gte(builder.Field("total").Subtract(5))).
+		Build()
+	c = bson.M{
+		"status": bson.M{"$eq": "active"},
+		"$expr":  bson.M{"$gt": []interface{}{"$updated_at", "$created_at"}},
+		"$and": []bson.M{
+			{"$expr": bson.M{"$gte": []interface{}{
+				bson.M{"$multiply": []interface{}{"$price", "$qty"}},
+				bson.M{"$subtract": []interface{}{"$total", 5}},
+			}}},
+		},
+	}
+	assert.Equal(t, c, b)
+
+	e := builder.Field("name").StrLenCP().Lt(10).
+		And(builder.Field("tags").Size().Eq(0)).
+		Or(builder.Field("created_at").DateDiff(builder.Field("updated_at"), "day").Gt(30))
+	assert.Equal(t, bson.M{"$or": []interface{}{
+		bson.M{"$and": []interface{}{
+			bson.M{"$lt": []interface{}{bson.M{"$strLenCP": "$name"}, 10}},
+			bson.M{"$eq": []interface{}{bson.M{"$size": "$tags"}, 0}},
+		}},
+		bson.M{"$gt": []interface{}{
+			bson.M{"$dateDiff": bson.D{
+				{Key: "startDate", Value: "$created_at"},
+				{Key: "endDate", Value: "$updated_at"},
+				{Key: "unit", Value: "day"},
+			}},
+			30,
+		}},
+	}}, e.Value())
+
+	// literal strings look like field paths are escaped
+	e = builder.Field("code").Eq("$abc").Add(builder.Lit(1))
+	assert.Equal(t, bson.M{"$add": []interface{}{
+		bson.M{"$eq": []interface{}{"$code", bson.M{"$literal": "$abc"}}},
+		bson.M{"$literal": 1},
+	}}, e.Value())
+}
@@ -0,0 +1,48 @@
+package builder
+
+import "go.mongodb.org/mongo-driver/bson/bsontype"
+
+// fieldCond represents a condition builder about the existence, null or BSON type of a field.
+//
+// MongoDB treats null and missing differently depending on the operator:
+//
+//   - {key: null} matches both documents whose key is null and documents without the key.
+//   - {key: {$type: "null"}} only matches documents whose key is explicitly null.
+//   - {key: {$exists: false}} only matches documents without the key.
+//   - {key: {$ne: null}} matches documents whose key exists and is not null.
+type fieldCond struct {
+	*cond
+}
+
+// newFieldCond constructs a new fieldCond.
+func newFieldCond(key string, builderRef *Builder) *fieldCond {
+	return &fieldCond{
+		cond: newCond(key, builderRef),
+	}
+}
+
+// Negate makes the following operators to be wrapped into `$not`, eg: Field("price").Negate().TypeAlias("number").
+func (c *fieldCond) Negate() *fieldCond {
+	c.cond.Negate()
+	return c
+}
+
+// IsNull adds `$type: "null"`, which only matches an explicit null value.
+func (c *fieldCond) IsNull() *Builder {
+	return c.Type(bsontype.Null)
+}
+
+// IsMissing adds `$exists: false`, which only matches documents without the field.
+func (c *fieldCond) IsMissing() *Builder {
+	return c.Exists(false)
+}
+
+// IsNullOrMissing adds `$eq: null`, which matches both null value and missing field.
+func (c *fieldCond) IsNullOrMissing() *Builder {
+	return c.Eq(nil)
+}
+
+// NotNull adds `$ne: null`, which matches documents whose field exists and is not null.
+func (c *fieldCond) NotNull() *Builder {
+	return c.Ne(nil)
+}
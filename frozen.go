@@ -0,0 +1,64 @@
+package builder
+
+import "go.mongodb.org/mongo-driver/bson"
+
+// Frozen is an immutable snapshot of a Builder, it's safe for concurrent use by multiple goroutines.
+//
+// Frozen is meant to be kept as a shared base filter, eg: a tenant scope,
+// and variants are derived from it with Derive or Builder.
+// Derived values share the unchanged parts of the expression tree with the base, nothing is copied eagerly.
+//
+// The zero value is an empty filter in strict mode.
+type Frozen struct {
+	b *Builder
+}
+
+// Freeze returns an immutable snapshot of the builder, see Frozen.
+// The snapshot is a deep copy, later chaining on the builder doesn't affect it.
+func (b *Builder) Freeze() Frozen {
+	return Frozen{b: b.Clone()}
+}
+
+// Builder returns a new Builder starting from the snapshot.
+// Chaining on the returned builder never affects the snapshot.
+func (f Frozen) Builder() *Builder {
+	if f.b == nil {
+		return New()
+	}
+	return &Builder{
+		branches: f.b.branches[:len(f.b.branches):len(f.b.branches)],
+		cur:      f.b.cur,
+		strict:   f.b.strict,
+		errs:     f.b.errs[:len(f.b.errs):len(f.b.errs)],
+		texts:    f.b.texts,
+	}
+}
+
+// Derive returns a new snapshot with conditions added by fn.
+//
+// Values given to fn are not copied, they should not be modified after Derive returns.
+func (f Frozen) Derive(fn func(*Builder)) Frozen {
+	b := f.Builder()
+	fn(b)
+	return Frozen{b: b}
+}
+
+// AST returns the expression tree of the snapshot, see Builder.AST.
+func (f Frozen) AST() Node {
+	return f.Builder().AST()
+}
+
+// Build builds final filter and returns it as bson.M, see Builder.Build.
+func (f Frozen) Build() bson.M {
+	return f.Builder().Build()
+}
+
+// BuildD builds final filter and returns it as bson.D, see Builder.BuildD.
+func (f Frozen) BuildD() bson.D {
+	return f.Builder().BuildD()
+}
+
+// BuildE builds final filter and returns all recorded errors, see Builder.BuildE.
+func (f Frozen) BuildE() (bson.M, error) {
+	return f.Builder().BuildE()
+}
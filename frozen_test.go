@@ -0,0 +1,95 @@
+package builder_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	builder "github.com/JsyTech/mongo-filter-builder"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestFrozen(t *testing.T) {
+	b := builder.New().Str("tenant").Eq("t1").Field("deleted_at").IsNullOrMissing()
+	base := b.Freeze()
+	b.Str("name").Eq("a")
+
+	expected := bson.M{"tenant": bson.M{"$eq": "t1"}, "deleted_at": bson.M{"$eq": nil}}
+	assert.Equal(t, expected, base.Build())
+
+	derived := base.Derive(func(b *builder.Builder) {
+		b.Num("age").Gt(18).Or().Num("age").Lt(10)
+	})
+	assert.Equal(t, bson.M{"$or": []bson.M{
+		{"tenant": bson.M{"$eq": "t1"}, "deleted_at": bson.M{"$eq": nil}, "age": bson.M{"$gt": 18}},
+		{"age": bson.M{"$lt": 10}},
+	}}, derived.Build())
+	assert.Equal(t, expected, base.Build())
+
+	// branches of a derived snapshot are not shared by its derivations
+	d1 := derived.Derive(func(b *builder.Builder) { b.Or().Str("name").Eq("x") })
+	d2 := derived.Derive(func(b *builder.Builder) { b.Or().Str("name").Eq("y") })
+	assert.Equal(t, "x", d1.Build()["$or"].([]bson.M)[2]["name"].(bson.M)["$eq"])
+	assert.Equal(t, "y", d2.Build()["$or"].([]bson.M)[2]["name"].(bson.M)["$eq"])
+
+	nb := base.Builder().RemoveCond("tenant", true)
+	assert.Equal(t, bson.M{"deleted_at": bson.M{"$eq": nil}}, nb.Build())
+	assert.Equal(t, expected, base.Build())
+
+	var zero builder.Frozen
+	assert.Equal(t, bson.M{}, zero.Build())
+	assert.Equal(t, bson.M{"a": bson.M{"$eq": 1}}, zero.Derive(func(b *builder.Builder) { b.Any("a").Eq(1) }).Build())
+
+	_, err := builder.NewSafe().Auto(1).Freeze().Derive(func(b *builder.Builder) { b.Auto(2) }).BuildE()
+	assert.NotNil(t, err)
+}
+
+func TestFrozen_Concurrent(t *testing.T) {
+	base := builder.New().Str("tenant").Eq("t1").Or().Str("tenant").Eq("t2").Freeze()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprint(i)
+			f := base.Derive(func(b *builder.Builder) {
+				b.Str("name").Eq(name).Or().RemoveCond("tenant", true).Num("age").Gt(i)
+			})
+			filter := f.Builder().Num("age").Lt(100).Build()
+			assert.Equal(t, bson.M{"$or": []bson.M{
+				{},
+				{"name": bson.M{"$eq": name}},
+				{"age": bson.M{"$gt": i, "$lt": 100}},
+			}}, filter)
+			assert.Len(t, base.Build()["$or"], 2)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestFrozen_ConcurrentRemoveCond(t *testing.T) {
+	base := builder.New().
+		Str("tenant").Eq("t1").Num("age").Gt(1).
+		Or().Str("tenant").Eq("t2").Num("age").Gt(2).
+		Or().Str("tenant").Eq("t3").Num("age").Gt(3).
+		Freeze()
+	expected := base.Build()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b := base.Builder().RemoveCond("tenant", true)
+			assert.Equal(t, bson.M{"$or": []bson.M{
+				{"age": bson.M{"$gt": 1}},
+				{"age": bson.M{"$gt": 2}},
+				{"age": bson.M{"$gt": 3}},
+			}}, b.Build())
+			assert.Equal(t, expected, base.Build())
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,98 @@
+package builder
+
+import (
+	"fmt"
+	"math"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Geometry represents a GeoJSON object which can be used in geospatial conditions.
+type Geometry interface {
+	// Validate reports whether the geometry is a valid GeoJSON object.
+	Validate() error
+	// GeoJSON returns the geometry as a GeoJSON document.
+	GeoJSON() bson.D
+}
+
+// Point represents a GeoJSON point in [longitude, latitude] order.
+type Point [2]float64
+
+// NewPoint constructs a new Point.
+func NewPoint(lng, lat float64) Point {
+	return Point{lng, lat}
+}
+
+// Validate checks the longitude is in [-180, 180] and the latitude is in [-90, 90].
+func (p Point) Validate() error {
+	lng, lat := p[0], p[1]
+	if math.IsNaN(lng) || lng < -180 || lng > 180 {
+		return fmt.Errorf("longitude %v out of range [-180, 180]", lng)
+	}
+	if math.IsNaN(lat) || lat < -90 || lat > 90 {
+		return fmt.Errorf("latitude %v out of range [-90, 90]", lat)
+	}
+	return nil
+}
+
+// GeoJSON returns {type: "Point", coordinates: [lng, lat]}.
+func (p Point) GeoJSON() bson.D {
+	return geoJSON("Point", p)
+}
+
+// Polygon represents a GeoJSON polygon.
+// The first ring is the exterior ring and the rest are holes.
+type Polygon [][]Point
+
+// Validate checks the polygon has at least one ring,
+// and every ring is closed with at least four valid points.
+func (p Polygon) Validate() error {
+	if len(p) == 0 {
+		return fmt.Errorf("polygon has no ring")
+	}
+	for i, ring := range p {
+		if len(ring) < 4 {
+			return fmt.Errorf("ring %d of polygon has %d points, at least 4 are required", i, len(ring))
+		}
+		if ring[0] != ring[len(ring)-1] {
+			return fmt.Errorf("ring %d of polygon is not closed", i)
+		}
+		for _, pt := range ring {
+			if err := pt.Validate(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// GeoJSON returns {type: "Polygon", coordinates: [[[lng, lat], ...], ...]}.
+func (p Polygon) GeoJSON() bson.D {
+	return geoJSON("Polygon", p)
+}
+
+// MultiPolygon represents a GeoJSON multi polygon.
+type MultiPolygon []Polygon
+
+// Validate checks there is at least one polygon and every polygon is valid.
+func (mp MultiPolygon) Validate() error {
+	if len(mp) == 0 {
+		return fmt.Errorf("multi polygon has no polygon")
+	}
+	for _, p := range mp {
+		if err := p.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GeoJSON returns {type: "MultiPolygon", coordinates: [...]}.
+func (mp MultiPolygon) GeoJSON() bson.D {
+	return geoJSON("MultiPolygon", mp)
+}
+
+// geoJSON returns {type: typ, coordinates: coordinates}.
+func geoJSON(typ string, coordinates interface{}) bson.D {
+	return bson.D{{Key: "type", Value: typ}, {Key: "coordinates", Value: coordinates}}
+}
@@ -0,0 +1,96 @@
+package builder
+
+import "go.mongodb.org/mongo-driver/bson"
+
+// geoCond represents a geospatial condition builder.
+// The condition fails if the given geometry is invalid.
+type geoCond struct {
+	*cond
+}
+
+// newGeoCond constructs a new geoCond.
+func newGeoCond(key string, builderRef *Builder) *geoCond {
+	return &geoCond{
+		cond: newCond(key, builderRef),
+	}
+}
+
+// Near adds `$near: {$geometry: point, $minDistance: minDist, $maxDistance: maxDist}` to the builder
+//
+// Distances are in meters, a non-positive distance will be omitted.
+func (c *geoCond) Near(point Point, minDist, maxDist float64) *Builder {
+	if !c.validate(_near, point) {
+		return c.builder
+	}
+	return c.cond.set(_near, nearMap(point, minDist, maxDist))
+}
+
+// NearSphere is like Near but uses `$nearSphere`.
+func (c *geoCond) NearSphere(point Point, minDist, maxDist float64) *Builder {
+	if !c.validate(_nearSphere, point) {
+		return c.builder
+	}
+	return c.cond.set(_nearSphere, nearMap(point, minDist, maxDist))
+}
+
+// Within adds `$geoWithin: {$geometry: g}` to the builder
+func (c *geoCond) Within(g Geometry) *Builder {
+	if !c.validate(_geoWithin, g) {
+		return c.builder
+	}
+	return c.cond.set(_geoWithin, bson.D{{Key: _geometry, Value: g.GeoJSON()}})
+}
+
+// WithinPolygon calls c.Within(polygon) under the wood
+func (c *geoCond) WithinPolygon(polygon Polygon) *Builder {
+	return c.Within(polygon)
+}
+
+// WithinBox adds `$geoWithin: {$box: [bottomLeft, upperRight]}` to the builder
+func (c *geoCond) WithinBox(bottomLeft, upperRight Point) *Builder {
+	if !c.validate(_geoWithin, bottomLeft, upperRight) {
+		return c.builder
+	}
+	return c.cond.set(_geoWithin, bson.D{{Key: _box, Value: []Point{bottomLeft, upperRight}}})
+}
+
+// WithinCenterSphere adds `$geoWithin: {$centerSphere: [center, radius]}` to the builder
+//
+// The radius is in radians, eg: distance in km / 6378.1.
+func (c *geoCond) WithinCenterSphere(center Point, radius float64) *Builder {
+	if !c.validate(_geoWithin, center) {
+		return c.builder
+	}
+	return c.cond.set(_geoWithin, bson.D{{Key: _centerSphere, Value: []interface{}{center, radius}}})
+}
+
+// Intersects adds `$geoIntersects: {$geometry: g}` to the builder
+func (c *geoCond) Intersects(g Geometry) *Builder {
+	if !c.validate(_geoIntersects, g) {
+		return c.builder
+	}
+	return c.cond.set(_geoIntersects, bson.D{{Key: _geometry, Value: g.GeoJSON()}})
+}
+
+// nearMap constructs the value of `$near` and `$nearSphere`.
+func nearMap(point Point, minDist, maxDist float64) bson.D {
+	d := bson.D{{Key: _geometry, Value: point.GeoJSON()}}
+	if minDist > 0 {
+		d = append(d, bson.E{Key: _minDistance, Value: minDist})
+	}
+	if maxDist > 0 {
+		d = append(d, bson.E{Key: _maxDistance, Value: maxDist})
+	}
+	return d
+}
+
+// validate validates geometries, ok is false if the condition of op fails.
+func (c *geoCond) validate(op string, geometries ...Geometry) (ok bool) {
+	for _, g := range geometries {
+		if err := g.Validate(); err != nil {
+			c.builder.fail(c.key, op, err)
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,71 @@
+package builder_test
+
+import (
+	"testing"
+
+	builder "github.com/JsyTech/mongo-filter-builder"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestGeometry_Validate(t *testing.T) {
+	assert.NoError(t, builder.NewPoint(120.1, 30.2).Validate())
+	assert.Error(t, builder.NewPoint(181, 0).Validate())
+	assert.Error(t, builder.NewPoint(0, -91).Validate())
+
+	square := builder.Polygon{{{0, 0}, {1, 0}, {1, 1}, {0, 1}, {0, 0}}}
+	assert.NoError(t, square.Validate())
+	assert.NoError(t, builder.MultiPolygon{square}.Validate())
+
+	assert.Error(t, builder.Polygon{}.Validate())
+	assert.Error(t, builder.Polygon{{{0, 0}, {1, 0}, {1, 1}, {0, 1}}}.Validate(), "ring not closed")
+	assert.Error(t, builder.Polygon{{{0, 0}, {1, 1}, {0, 0}}}.Validate(), "too few points")
+	assert.Error(t, builder.MultiPolygon{}.Validate())
+}
+
+func TestGeoCond(t *testing.T) {
+	p := builder.NewPoint(120.1, 30.2)
+
+	b := builder.New().Geo("loc").Near(p, 0, 1000).Build()
+	c := bson.M{"loc": bson.M{"$near": bson.M{
+		"$geometry":    bson.M{"type": "Point", "coordinates": p},
+		"$maxDistance": float64(1000),
+	}}}
+	assert.Equal(t, c, b)
+
+	b = builder.New().Geo("loc").NearSphere(p, 10, 0).Build()
+	c = bson.M{"loc": bson.M{"$nearSphere": bson.M{
+		"$geometry":    bson.M{"type": "Point", "coordinates": p},
+		"$minDistance": float64(10),
+	}}}
+	assert.Equal(t, c, b)
+
+	square := builder.Polygon{{{0, 0}, {1, 0}, {1, 1}, {0, 1}, {0, 0}}}
+	b = builder.New().Geo("loc").WithinPolygon(square).Build()
+	c = bson.M{"loc": bson.M{"$geoWithin": bson.M{
+		"$geometry": bson.M{"type": "Polygon", "coordinates": square},
+	}}}
+	assert.Equal(t, c, b)
+
+	b = builder.New().Geo("loc").WithinBox(builder.NewPoint(0, 0), builder.NewPoint(1, 1)).Build()
+	c = bson.M{"loc": bson.M{"$geoWithin": bson.M{
+		"$box": []builder.Point{{0, 0}, {1, 1}},
+	}}}
+	assert.Equal(t, c, b)
+
+	b = builder.New().Geo("loc").WithinCenterSphere(p, 0.01).Build()
+	c = bson.M{"loc": bson.M{"$geoWithin": bson.M{
+		"$centerSphere": []interface{}{p, 0.01},
+	}}}
+	assert.Equal(t, c, b)
+
+	b = builder.New().Geo("area").Intersects(p).Build()
+	c = bson.M{"area": bson.M{"$geoIntersects": bson.M{
+		"$geometry": bson.M{"type": "Point", "coordinates": p},
+	}}}
+	assert.Equal(t, c, b)
+
+	assert.Panics(t, func() {
+		builder.New().Geo("loc").WithinPolygon(builder.Polygon{{{0, 0}, {1, 0}, {1, 1}, {0, 1}}})
+	})
+}
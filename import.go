@@ -0,0 +1,248 @@
+package builder
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FromExtJSON reconstructs a Builder from a filter in MongoDB Extended JSON (canonical or relaxed).
+// See FromBSON for how the filter is reconstructed.
+func FromExtJSON(data []byte) (*Builder, error) {
+	var d bson.D
+	if err := bson.UnmarshalExtJSON(data, false, &d); err != nil {
+		return nil, fmt.Errorf("filterBuilder: failed to parse extended json, err: %v", err)
+	}
+	return FromBSOND(d)
+}
+
+// FromBSON reconstructs a Builder from a filter, thus the fluent API can be used on it.
+//
+// If the filter only contains `$or`, each branch will be a condition separated by Or(),
+// and the last branch will be the current condition.
+// A field with a literal value such as {name: "a"} will be converted to {name: {$eq: "a"}},
+// and a regex literal will be converted to `$regex`.
+//
+// Keys of bson.M are sorted to keep the builder deterministic.
+func FromBSON(m bson.M) (*Builder, error) {
+	return FromBSOND(sortedDoc(m))
+}
+
+// FromBSOND is like FromBSON but keeps the order of the given filter.
+func FromBSOND(d bson.D) (*Builder, error) {
+	nodes, err := importCond(d)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 1 {
+		if or, ok := nodes[0].(*Or); ok {
+			return FromAST(or), nil
+		}
+	}
+	return FromAST(&And{Children: nodes}), nil
+}
+
+// importCond converts a condition document to nodes.
+func importCond(d bson.D) ([]Node, error) {
+	res := make([]Node, 0, len(d))
+	for _, e := range d {
+		switch {
+		case e.Key == _and:
+			branches, err := importBranches(e.Key, e.Value)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, branches...)
+		case e.Key == _or || e.Key == _nor:
+			branches, err := importBranches(e.Key, e.Value)
+			if err != nil {
+				return nil, err
+			}
+			if e.Key == _or {
+				res = append(res, &Or{Children: branches})
+			} else {
+				res = append(res, &Nor{Children: branches})
+			}
+		case e.Key == _text:
+			if t, ok := importText(e.Value); ok {
+				res = append(res, t)
+				continue
+			}
+			res = append(res, &Raw{Key: e.Key, Value: importDocs(e.Value)})
+		case e.Key == _expr:
+			res = append(res, &Expr{Value: importDocs(e.Value)})
+		case strings.HasPrefix(e.Key, "$"):
+			res = append(res, &Raw{Key: e.Key, Value: importDocs(e.Value)})
+		default:
+			res = append(res, importOps(e.Key, e.Value)...)
+		}
+	}
+	return res, nil
+}
+
+// importBranches converts the array of a logical operator to branches.
+func importBranches(op string, val interface{}) ([]Node, error) {
+	rv := reflect.ValueOf(val)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, fmt.Errorf("filterBuilder: %s should be an array, got %T", op, val)
+	}
+	branches := make([]Node, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		d, ok := asDoc(rv.Index(i).Interface())
+		if !ok {
+			return nil, fmt.Errorf("filterBuilder: element %d of %s should be a document, got %T", i, op, rv.Index(i).Interface())
+		}
+		nodes, err := importCond(d)
+		if err != nil {
+			return nil, err
+		}
+		branches = append(branches, &And{Children: nodes})
+	}
+	return branches, nil
+}
+
+// importOps converts the value of a field to field-level nodes.
+func importOps(field string, val interface{}) []Node {
+	d, ok := asDoc(val)
+	if !ok || !isOpsDoc(d) {
+		if re, ok := val.(primitive.Regex); ok {
+			return []Node{&Compare{Field: field, Op: _regex, Value: re}}
+		}
+		return []Node{&Compare{Field: field, Op: _eq, Value: val}}
+	}
+
+	nodes := make([]Node, 0, len(d))
+	for _, e := range d {
+		switch e.Key {
+		case _not:
+			if not, ok := asDoc(e.Value); ok && isOpsDoc(not) {
+				n := &Not{Field: field}
+				for _, op := range importOps(field, not) {
+					if c, ok := op.(*Compare); ok {
+						n.Ops = append(n.Ops, c)
+					}
+				}
+				nodes = append(nodes, n)
+				continue
+			}
+			if re, ok := e.Value.(primitive.Regex); ok {
+				nodes = append(nodes, &Not{Field: field, Ops: []*Compare{{Field: field, Op: _regex, Value: re}}})
+				continue
+			}
+			nodes = append(nodes, &Compare{Field: field, Op: _not, Value: importDocs(e.Value)})
+		case _elemMatch:
+			if em, ok := importElemMatch(field, e.Value); ok {
+				nodes = append(nodes, em)
+				continue
+			}
+			nodes = append(nodes, &Compare{Field: field, Op: _elemMatch, Value: importDocs(e.Value)})
+		default:
+			v := e.Value
+			if !userValueOps[e.Key] {
+				v = importDocs(v)
+			}
+			nodes = append(nodes, &Compare{Field: field, Op: e.Key, Value: v})
+		}
+	}
+	return nodes
+}
+
+// importElemMatch converts the value of `$elemMatch` to an ElemMatch node.
+// Operators of scalar elements are converted to conditions with an empty field.
+func importElemMatch(field string, val interface{}) (*ElemMatch, bool) {
+	d, ok := asDoc(val)
+	if !ok {
+		return nil, false
+	}
+	if isOpsDoc(d) && !isLogicalOp(d[0].Key) {
+		return &ElemMatch{Field: field, Filter: &And{Children: importOps("", d)}}, true
+	}
+	nodes, err := importCond(d)
+	if err != nil {
+		return nil, false
+	}
+	return &ElemMatch{Field: field, Filter: &And{Children: nodes}}, true
+}
+
+// isLogicalOp reports whether op is a top level logical operator.
+func isLogicalOp(op string) bool {
+	return op == _and || op == _or || op == _nor
+}
+
+// importText converts the value of `$text` to a Text node.
+func importText(val interface{}) (*Text, bool) {
+	d, ok := asDoc(val)
+	if !ok {
+		return nil, false
+	}
+	t := &Text{}
+	for _, e := range d {
+		var ok bool
+		switch e.Key {
+		case _search:
+			t.Search, ok = e.Value.(string)
+		case _language:
+			t.Language, ok = e.Value.(string)
+		case _caseSensitive:
+			var v bool
+			v, ok = e.Value.(bool)
+			t.CaseSensitive = &v
+		case _diacriticSensitive:
+			var v bool
+			v, ok = e.Value.(bool)
+			t.DiacriticSensitive = &v
+		}
+		if !ok {
+			return nil, false
+		}
+	}
+	return t, true
+}
+
+// importDocs converts nested bson.M to bson.D recursively.
+func importDocs(val interface{}) interface{} {
+	switch v := val.(type) {
+	case bson.M:
+		return importDocs(sortedDoc(v))
+	case bson.D:
+		d := make(bson.D, 0, len(v))
+		for _, e := range v {
+			d = append(d, bson.E{Key: e.Key, Value: importDocs(e.Value)})
+		}
+		return d
+	case bson.A:
+		a := make(bson.A, 0, len(v))
+		for _, e := range v {
+			a = append(a, importDocs(e))
+		}
+		return a
+	case []interface{}:
+		a := make([]interface{}, 0, len(v))
+		for _, e := range v {
+			a = append(a, importDocs(e))
+		}
+		return a
+	}
+	return val
+}
+
+// asDoc converts val to bson.D if it's a document.
+func asDoc(val interface{}) (bson.D, bool) {
+	switch v := val.(type) {
+	case bson.D:
+		return v, true
+	case bson.M:
+		return sortedDoc(v), true
+	case map[string]interface{}:
+		return sortedDoc(v), true
+	}
+	return nil, false
+}
+
+// isOpsDoc reports whether d is an operator document such as {$gt: 1}.
+func isOpsDoc(d bson.D) bool {
+	return len(d) != 0 && strings.HasPrefix(d[0].Key, "$")
+}
@@ -0,0 +1,132 @@
+package builder_test
+
+import (
+	"testing"
+	"time"
+
+	builder "github.com/JsyTech/mongo-filter-builder"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestFromBSON(t *testing.T) {
+	b, err := builder.FromBSON(bson.M{
+		"name": "a",
+		"age":  bson.M{"$gte": 18},
+		"tag":  primitive.Regex{Pattern: "^x"},
+	})
+	assert.NoError(t, err)
+	b.Num("age").Lte(60).Str("city").Eq("hz").RemoveCond("tag")
+	c := builder.New().
+		Num("age").Gte(18).
+		Num("age").Lte(60).
+		Str("name").Eq("a").
+		Str("city").Eq("hz").
+		Build()
+	assert.Equal(t, c, b.Build())
+
+	// $or branches are imported as condition maps
+	b, err = builder.FromBSON(bson.M{"$or": []bson.M{
+		{"name": "a"},
+		{"name": "b"},
+	}})
+	assert.NoError(t, err)
+	b.Num("age").Gt(1).Or().Str("name").Eq("c")
+	c = builder.New().
+		Str("name").Eq("a").
+		Or().
+		Str("name").Eq("b").Num("age").Gt(1).
+		Or().
+		Str("name").Eq("c").
+		Build()
+	assert.Equal(t, c, b.Build())
+
+	// nested logical operators
+	b, err = builder.FromBSON(bson.M{
+		"status": "active",
+		"$or":    bson.A{bson.M{"owner": "me"}, bson.M{"shared": true}},
+	})
+	assert.NoError(t, err)
+	c = builder.New().
+		OrGroup(func(g *builder.Builder) {
+			g.Str("owner").Eq("me").Or().Any("shared").Eq(true)
+		}).
+		Str("status").Eq("active").
+		Build()
+	assert.Equal(t, c, b.Build())
+
+	// a negated regex is imported like the one added by Not
+	b, err = builder.FromBSON(bson.M{"name": bson.M{"$not": primitive.Regex{Pattern: "^x"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, builder.New().Str("name").Not("^x").AST(), b.AST())
+
+	_, err = builder.FromBSON(bson.M{"$or": "bad"})
+	assert.Error(t, err)
+	_, err = builder.FromBSON(bson.M{"$and": []interface{}{1}})
+	assert.Error(t, err)
+}
+
+func TestFromExtJSON(t *testing.T) {
+	id := primitive.NewObjectID()
+	at := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	src := builder.New().
+		Oid().Eq(id.Hex()).
+		Date("created_at").Gte(at).
+		Str("name").RegexWithOpt("^a", "i").
+		Or().
+		Num("age").Negate().Gt(int32(5))
+
+	for _, canonical := range []bool{true, false} {
+		s, err := src.ToExtJSON(canonical)
+		assert.NoError(t, err)
+		b, err := builder.FromExtJSON([]byte(s))
+		assert.NoError(t, err)
+		s2, err := b.ToExtJSON(canonical)
+		assert.NoError(t, err)
+		assert.Equal(t, s, s2)
+	}
+
+	_, err := builder.FromExtJSON([]byte(`{"a": `))
+	assert.Error(t, err)
+}
+
+func TestFromShell(t *testing.T) {
+	id := primitive.NewObjectID()
+	src := builder.New().
+		Oid().Eq(id.Hex()).
+		Date("created_at").Gte(time.Date(2021, 1, 2, 3, 4, 5, 6000000, time.UTC)).
+		Str("name").RegexWithOpt("^a/b", "i").
+		Num("big").Gt(int64(1) << 40).
+		Num("score").Lt(0.5).
+		Str("tags.0").Negate().Eq(`say "hi"`).
+		Or().
+		Num("age").In([]int32{1, 2})
+
+	b, err := builder.FromShell(src.ToShell())
+	assert.NoError(t, err)
+	assert.Equal(t, src.ToShell(), b.ToShell())
+
+	b, err = builder.FromShell(`{
+		name: 'a',
+		"age": {$gte: NumberInt(18), },
+		at: {$lt: new Date("2021-01-02")},
+		bin: BinData(0, "AQI="),
+		dec: NumberDecimal("1.5"),
+	}`)
+	assert.NoError(t, err)
+	dec, _ := primitive.ParseDecimal128("1.5")
+	c := bson.D{
+		{Key: "name", Value: bson.D{{Key: "$eq", Value: "a"}}},
+		{Key: "age", Value: bson.D{{Key: "$gte", Value: int32(18)}}},
+		{Key: "at", Value: bson.D{{Key: "$lt", Value: time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)}}},
+		{Key: "bin", Value: bson.D{{Key: "$eq", Value: primitive.Binary{Data: []byte{1, 2}}}}},
+		{Key: "dec", Value: bson.D{{Key: "$eq", Value: dec}}},
+	}
+	assert.Equal(t, c, b.BuildD())
+
+	for _, bad := range []string{`{a: }`, `{a: 1`, `[1]`, `{a: Foo()}`, `{a: ObjectId("x")}`, `{a: 1} b`} {
+		_, err := builder.FromShell(bad)
+		assert.Error(t, err, bad)
+	}
+}
@@ -0,0 +1,719 @@
+package builder
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const _options = "$options"
+
+// Match reports whether doc is matched by filter with the query semantics of MongoDB,
+// doc can be anything which can be marshaled to a BSON document, such as a struct, bson.M or bson.D.
+//
+// All operators the builder emits are supported except `$text`, which requires a text index.
+// Values are compared in the BSON comparison order with type bracketing,
+// and dotted paths traverse arrays and embedded documents like MongoDB does.
+// Geospatial operators evaluate polygons on a flat plane of longitude and latitude,
+// while distances are measured on the sphere.
+//
+// An error is returned if the filter is malformed or uses an unsupported operator.
+func Match(filter bson.M, doc any) (bool, error) {
+	f, err := normalizeDoc(filter)
+	if err != nil {
+		return false, fmt.Errorf("filterBuilder: invalid filter, err: %v", err)
+	}
+	d, err := normalizeDoc(doc)
+	if err != nil {
+		return false, fmt.Errorf("filterBuilder: invalid document, err: %v", err)
+	}
+	return matchDoc(f, d)
+}
+
+// normalizeDoc converts v to bson.D with values of the types decoded by the driver.
+func normalizeDoc(v interface{}) (bson.D, error) {
+	data, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var d bson.D
+	if err := bson.Unmarshal(data, &d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// matchDoc reports whether doc is matched by the normalized filter.
+func matchDoc(filter, doc bson.D) (bool, error) {
+	for _, e := range filter {
+		ok, err := matchElem(e, doc)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// matchElem reports whether doc is matched by a top level element of the filter.
+func matchElem(e bson.E, doc bson.D) (bool, error) {
+	switch e.Key {
+	case _and, _or, _nor:
+		filters, err := docList(e.Key, e.Value)
+		if err != nil {
+			return false, err
+		}
+		return matchLogic(e.Key, filters, doc)
+	case _expr:
+		v, err := evalExpr(e.Value, doc)
+		if err != nil {
+			return false, err
+		}
+		return truthy(v), nil
+	case _jsonSchema:
+		schema, ok := e.Value.(bson.D)
+		if !ok {
+			return false, fmt.Errorf("filterBuilder: %s should be a document, got %T", e.Key, e.Value)
+		}
+		return matchSchema(schema, doc)
+	case "$comment":
+		return true, nil
+	}
+	if strings.HasPrefix(e.Key, "$") {
+		return false, fmt.Errorf("filterBuilder: %s is not supported by Match", e.Key)
+	}
+	return matchField(resolvePath(doc, strings.Split(e.Key, ".")), e.Value)
+}
+
+// docList converts the value of a logical operator to filters.
+func docList(op string, val interface{}) ([]bson.D, error) {
+	arr, ok := val.(bson.A)
+	if !ok || len(arr) == 0 {
+		return nil, fmt.Errorf("filterBuilder: %s should be a non-empty array", op)
+	}
+	res := make([]bson.D, 0, len(arr))
+	for i, v := range arr {
+		d, ok := v.(bson.D)
+		if !ok {
+			return nil, fmt.Errorf("filterBuilder: element %d of %s should be a document, got %T", i, op, v)
+		}
+		res = append(res, d)
+	}
+	return res, nil
+}
+
+// matchLogic evaluates `$and`, `$or` and `$nor`.
+func matchLogic(op string, filters []bson.D, doc bson.D) (bool, error) {
+	for _, f := range filters {
+		ok, err := matchDoc(f, doc)
+		if err != nil {
+			return false, err
+		}
+		switch {
+		case op == _and && !ok:
+			return false, nil
+		case op == _or && ok:
+			return true, nil
+		case op == _nor && ok:
+			return false, nil
+		}
+	}
+	return op != _or, nil
+}
+
+// missingValue represents a missing field in resolved values.
+type missingValue struct{}
+
+var missing = missingValue{}
+
+// resolvePath returns the values at path in v, arrays in the middle of the path are traversed.
+// A missing field results in a missing value.
+func resolvePath(v interface{}, path []string) []interface{} {
+	if len(path) == 0 {
+		return []interface{}{v}
+	}
+	switch v := v.(type) {
+	case bson.D:
+		field, ok := docGet(v, path[0])
+		if !ok {
+			return []interface{}{missing}
+		}
+		return resolvePath(field, path[1:])
+	case bson.A:
+		res := []interface{}{}
+		if i, err := strconv.Atoi(path[0]); err == nil && i >= 0 && i < len(v) {
+			res = append(res, resolvePath(v[i], path[1:])...)
+		}
+		for _, elem := range v {
+			if d, ok := elem.(bson.D); ok {
+				res = append(res, resolvePath(d, path)...)
+			}
+		}
+		if len(res) == 0 {
+			return []interface{}{missing}
+		}
+		return res
+	}
+	return []interface{}{missing}
+}
+
+// matchField reports whether the values of a field are matched by cond,
+// which is either an operator document or a literal value.
+func matchField(vals []interface{}, cond interface{}) (bool, error) {
+	if d, ok := cond.(bson.D); ok && isOpsDoc(d) {
+		return matchOps(vals, d)
+	}
+	if re, ok := cond.(primitive.Regex); ok {
+		return matchOp(vals, _regex, re)
+	}
+	return matchOp(vals, _eq, cond)
+}
+
+// matchOps reports whether the values are matched by all operators.
+func matchOps(vals []interface{}, ops bson.D) (bool, error) {
+	for _, e := range ops {
+		arg := e.Value
+		switch e.Key {
+		case _options:
+			if _, ok := docGet(ops, _regex); !ok {
+				return false, fmt.Errorf("filterBuilder: %s without %s", _options, _regex)
+			}
+			continue
+		case _regex:
+			re, err := regexArg(arg, ops)
+			if err != nil {
+				return false, err
+			}
+			arg = re
+		case _minDistance, _maxDistance:
+			// handled by $near and $nearSphere
+			continue
+		}
+		ok, err := matchOp(vals, e.Key, arg)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// regexArg returns the regex of `$regex` combined with `$options`.
+func regexArg(arg interface{}, ops bson.D) (primitive.Regex, error) {
+	opts, _ := docGet(ops, _options)
+	optStr, _ := opts.(string)
+	switch re := arg.(type) {
+	case primitive.Regex:
+		if optStr != "" {
+			re.Options = optStr
+		}
+		return re, nil
+	case string:
+		return primitive.Regex{Pattern: re, Options: optStr}, nil
+	}
+	return primitive.Regex{}, fmt.Errorf("filterBuilder: %s should be a string or a regex, got %T", _regex, arg)
+}
+
+// matchOp reports whether the values are matched by `op: arg`.
+func matchOp(vals []interface{}, op string, arg interface{}) (bool, error) {
+	switch op {
+	case _eq:
+		return anyExpanded(vals, func(v interface{}) (bool, error) {
+			return equalValues(v, arg), nil
+		})
+	case _ne:
+		ok, err := matchOp(vals, _eq, arg)
+		return !ok, err
+	case _gt, _gte, _lt, _lte:
+		return anyExpanded(vals, func(v interface{}) (bool, error) {
+			return compareOp(op, v, arg), nil
+		})
+	case _in:
+		list, ok := arg.(bson.A)
+		if !ok {
+			return false, fmt.Errorf("filterBuilder: %s should be an array, got %T", op, arg)
+		}
+		return anyExpanded(vals, func(v interface{}) (bool, error) {
+			return inList(v, list)
+		})
+	case _nin:
+		ok, err := matchOp(vals, _in, arg)
+		return !ok, err
+	case _exists:
+		exists := false
+		for _, v := range vals {
+			if v != missing {
+				exists = true
+			}
+		}
+		return exists == truthy(arg), nil
+	case _type:
+		return anyExpanded(vals, func(v interface{}) (bool, error) {
+			return matchType(v, arg)
+		})
+	case _regex:
+		re, ok := arg.(primitive.Regex)
+		if !ok {
+			return false, fmt.Errorf("filterBuilder: %s should be a regex, got %T", op, arg)
+		}
+		return anyExpanded(vals, func(v interface{}) (bool, error) {
+			return matchRegex(v, re)
+		})
+	case _not:
+		var (
+			ok  bool
+			err error
+		)
+		switch a := arg.(type) {
+		case primitive.Regex:
+			ok, err = matchOp(vals, _regex, a)
+		case bson.D:
+			ok, err = matchOps(vals, a)
+		default:
+			return false, fmt.Errorf("filterBuilder: %s should be a document or a regex, got %T", op, arg)
+		}
+		return !ok && err == nil, err
+	case _size:
+		size, ok := integral(arg)
+		if !ok {
+			return false, fmt.Errorf("filterBuilder: %s should be an integer, got %v", op, arg)
+		}
+		for _, v := range vals {
+			if arr, ok := v.(bson.A); ok && int64(len(arr)) == size {
+				return true, nil
+			}
+		}
+		return false, nil
+	case _all:
+		return matchAll(vals, arg)
+	case _elemMatch:
+		return matchElemMatch(vals, arg)
+	case _mod:
+		return matchMod(vals, arg)
+	case _bitsAllSet, _bitsAnySet, _bitsAllClear, _bitsAnyClear:
+		return matchBits(vals, op, arg)
+	case _near, _nearSphere, _geoWithin, _geoIntersects:
+		return matchGeo(vals, op, arg)
+	}
+	return false, fmt.Errorf("filterBuilder: %s is not supported by Match", op)
+}
+
+// anyExpanded reports whether any of the values or elements of array values is matched by fn.
+func anyExpanded(vals []interface{}, fn func(v interface{}) (bool, error)) (bool, error) {
+	for _, v := range vals {
+		ok, err := fn(v)
+		if err != nil || ok {
+			return ok, err
+		}
+		arr, isArr := v.(bson.A)
+		if !isArr {
+			continue
+		}
+		for _, elem := range arr {
+			ok, err := fn(elem)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+	}
+	return false, nil
+}
+
+// equalValues reports whether v equals arg, a null arg matches missing fields.
+func equalValues(v, arg interface{}) bool {
+	if v == missing {
+		return arg == nil
+	}
+	return typeOrder(v) == typeOrder(arg) && compareNormalized(v, arg) == 0
+}
+
+// compareOp compares v with arg of the same type bracket, a null arg matches missing fields by `$gte` and `$lte`.
+func compareOp(op string, v, arg interface{}) bool {
+	if v == missing {
+		v = nil
+		if arg != nil {
+			return false
+		}
+	}
+	_, minKey := arg.(primitive.MinKey)
+	_, maxKey := arg.(primitive.MaxKey)
+	if !minKey && !maxKey && typeOrder(v) != typeOrder(arg) {
+		return false
+	}
+	cmp := compareNormalized(v, arg)
+	switch op {
+	case _gt:
+		return cmp > 0
+	case _gte:
+		return cmp >= 0
+	case _lt:
+		return cmp < 0
+	}
+	return cmp <= 0
+}
+
+// inList reports whether v equals any value of list or is matched by a regex in list.
+func inList(v interface{}, list bson.A) (bool, error) {
+	for _, a := range list {
+		if re, ok := a.(primitive.Regex); ok {
+			if ok, err := matchRegex(v, re); err != nil || ok {
+				return ok, err
+			}
+			continue
+		}
+		if equalValues(v, a) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// matchRegex reports whether the string v is matched by re, a regex v matches the same regex.
+func matchRegex(v interface{}, re primitive.Regex) (bool, error) {
+	var s string
+	switch v := v.(type) {
+	case string:
+		s = v
+	case primitive.Symbol:
+		s = string(v)
+	case primitive.Regex:
+		return v == re, nil
+	default:
+		return false, nil
+	}
+	compiled, err := compileRegex(re)
+	if err != nil {
+		return false, err
+	}
+	return compiled.MatchString(s), nil
+}
+
+// compileRegex compiles re with its options, only `i`, `m`, `s` and `u` are supported.
+func compileRegex(re primitive.Regex) (*regexp.Regexp, error) {
+	flags := ""
+	for _, o := range re.Options {
+		switch o {
+		case 'i', 'm', 's':
+			flags += string(o)
+		case 'u':
+		default:
+			return nil, fmt.Errorf("filterBuilder: regex option %q is not supported by Match", o)
+		}
+	}
+	pattern := re.Pattern
+	if flags != "" {
+		pattern = "(?" + flags + ")" + pattern
+	}
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("filterBuilder: invalid regex /%s/%s, err: %v", re.Pattern, re.Options, err)
+	}
+	return compiled, nil
+}
+
+// typeCodes maps aliases of `$type` to BSON type codes.
+var typeCodes = map[string]int32{
+	"double": 1, "string": 2, "object": 3, "array": 4, "binData": 5, "undefined": 6,
+	"objectId": 7, "bool": 8, "date": 9, "null": 10, "regex": 11, "dbPointer": 12,
+	"javascript": 13, "symbol": 14, "javascriptWithScope": 15, "int": 16, "timestamp": 17,
+	"long": 18, "decimal": 19, "minKey": -1, "maxKey": 127,
+}
+
+// typeCode returns the BSON type code of a normalized value.
+func typeCode(v interface{}) (int32, bool) {
+	switch v.(type) {
+	case float64:
+		return 1, true
+	case string:
+		return 2, true
+	case bson.D:
+		return 3, true
+	case bson.A:
+		return 4, true
+	case primitive.Binary:
+		return 5, true
+	case primitive.Undefined:
+		return 6, true
+	case primitive.ObjectID:
+		return 7, true
+	case bool:
+		return 8, true
+	case primitive.DateTime:
+		return 9, true
+	case nil, primitive.Null:
+		return 10, true
+	case primitive.Regex:
+		return 11, true
+	case primitive.DBPointer:
+		return 12, true
+	case primitive.JavaScript:
+		return 13, true
+	case primitive.Symbol:
+		return 14, true
+	case primitive.CodeWithScope:
+		return 15, true
+	case int32:
+		return 16, true
+	case primitive.Timestamp:
+		return 17, true
+	case int64:
+		return 18, true
+	case primitive.Decimal128:
+		return 19, true
+	case primitive.MinKey:
+		return -1, true
+	case primitive.MaxKey:
+		return 127, true
+	}
+	return 0, false
+}
+
+// matchType reports whether the type of v is one of the types of `$type`.
+func matchType(v, arg interface{}) (bool, error) {
+	code, ok := typeCode(v)
+	if !ok {
+		return false, nil
+	}
+	types, isArr := arg.(bson.A)
+	if !isArr {
+		types = bson.A{arg}
+	}
+	for _, t := range types {
+		switch t := t.(type) {
+		case string:
+			if t == "number" {
+				if typeOrder(v) == 3 {
+					return true, nil
+				}
+				continue
+			}
+			c, ok := typeCodes[t]
+			if !ok {
+				return false, fmt.Errorf("filterBuilder: unknown type alias %q", t)
+			}
+			if c == code {
+				return true, nil
+			}
+		default:
+			c, ok := integral(t)
+			if !ok {
+				return false, fmt.Errorf("filterBuilder: %s should be type codes or aliases, got %v", _type, t)
+			}
+			if c == int64(code) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// matchAll evaluates `$all` as `$and` of `$eq`, or `$regex` for regex values.
+func matchAll(vals []interface{}, arg interface{}) (bool, error) {
+	list, ok := arg.(bson.A)
+	if !ok {
+		return false, fmt.Errorf("filterBuilder: %s should be an array, got %T", _all, arg)
+	}
+	if len(list) == 0 {
+		return false, nil
+	}
+	for _, a := range list {
+		op := _eq
+		if _, isRegex := a.(primitive.Regex); isRegex {
+			op = _regex
+		}
+		ok, err := matchOp(vals, op, a)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// matchElemMatch reports whether any element of array values is matched by the filter of `$elemMatch`.
+func matchElemMatch(vals []interface{}, arg interface{}) (bool, error) {
+	filter, ok := arg.(bson.D)
+	if !ok {
+		return false, fmt.Errorf("filterBuilder: %s should be a document, got %T", _elemMatch, arg)
+	}
+	scalar := isOpsDoc(filter) && !isLogicalOp(filter[0].Key)
+	for _, v := range vals {
+		arr, ok := v.(bson.A)
+		if !ok {
+			continue
+		}
+		for _, elem := range arr {
+			var (
+				ok  bool
+				err error
+			)
+			if scalar {
+				ok, err = matchOps([]interface{}{elem}, filter)
+			} else if d, isDoc := elem.(bson.D); isDoc {
+				ok, err = matchDoc(filter, d)
+			}
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+	}
+	return false, nil
+}
+
+// integral converts a number with an integral value to int64.
+func integral(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case float64:
+		if n != math.Trunc(n) || n < math.MinInt64 || n >= math.MaxInt64 {
+			return 0, false
+		}
+		return int64(n), true
+	case primitive.Decimal128:
+		f, nan := bigFloat(n)
+		if nan || f.IsInf() || !f.IsInt() {
+			return 0, false
+		}
+		i, acc := f.Int64()
+		return i, acc == 0
+	}
+	return 0, false
+}
+
+// toFloat converts a number to float64.
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	case primitive.Decimal128:
+		f, nan := bigFloat(n)
+		if nan {
+			return math.NaN(), true
+		}
+		res, _ := f.Float64()
+		return res, true
+	}
+	return 0, false
+}
+
+// matchMod evaluates `$mod: [divisor, remainder]`, numbers are truncated towards zero.
+func matchMod(vals []interface{}, arg interface{}) (bool, error) {
+	list, ok := arg.(bson.A)
+	if !ok || len(list) != 2 {
+		return false, fmt.Errorf("filterBuilder: %s should be an array of divisor and remainder", _mod)
+	}
+	divisor, ok1 := toFloat(list[0])
+	remainder, ok2 := toFloat(list[1])
+	if !ok1 || !ok2 || math.IsNaN(divisor) || math.IsNaN(remainder) {
+		return false, fmt.Errorf("filterBuilder: divisor and remainder of %s should be numbers", _mod)
+	}
+	d, r := int64(divisor), int64(remainder)
+	if d == 0 {
+		return false, fmt.Errorf("filterBuilder: divisor of %s can't be 0", _mod)
+	}
+	return anyExpanded(vals, func(v interface{}) (bool, error) {
+		f, ok := toFloat(v)
+		if !ok || math.IsNaN(f) || math.IsInf(f, 0) {
+			return false, nil
+		}
+		return int64(f)%d == r, nil
+	})
+}
+
+// matchBits evaluates bitwise operators, the mask is a number, an array of bit positions or BinData.
+func matchBits(vals []interface{}, op string, arg interface{}) (bool, error) {
+	positions, err := bitPositions(op, arg)
+	if err != nil {
+		return false, err
+	}
+	return anyExpanded(vals, func(v interface{}) (bool, error) {
+		bit, ok := bitsOf(v)
+		if !ok {
+			return false, nil
+		}
+		set, clear := 0, 0
+		for _, p := range positions {
+			if bit(p) {
+				set++
+			} else {
+				clear++
+			}
+		}
+		switch op {
+		case _bitsAllSet:
+			return clear == 0, nil
+		case _bitsAnySet:
+			return set != 0, nil
+		case _bitsAllClear:
+			return set == 0, nil
+		}
+		return clear != 0, nil
+	})
+}
+
+// bitPositions returns the bit positions of the mask of a bitwise operator.
+func bitPositions(op string, arg interface{}) ([]int64, error) {
+	switch a := arg.(type) {
+	case bson.A:
+		positions := make([]int64, 0, len(a))
+		for _, p := range a {
+			pos, ok := integral(p)
+			if !ok || pos < 0 {
+				return nil, fmt.Errorf("filterBuilder: bit positions of %s should be non-negative integers, got %v", op, p)
+			}
+			positions = append(positions, pos)
+		}
+		return positions, nil
+	case primitive.Binary:
+		positions := []int64{}
+		for i, b := range a.Data {
+			for j := 0; j < 8; j++ {
+				if b&(1<<j) != 0 {
+					positions = append(positions, int64(i*8+j))
+				}
+			}
+		}
+		return positions, nil
+	}
+	mask, ok := integral(arg)
+	if !ok || mask < 0 || mask > math.MaxInt32 {
+		return nil, fmt.Errorf("filterBuilder: bitmask of %s should be an integer in [0, %d], got %v", op, math.MaxInt32, arg)
+	}
+	positions := []int64{}
+	for i := int64(0); i < 31; i++ {
+		if mask&(1<<i) != 0 {
+			positions = append(positions, i)
+		}
+	}
+	return positions, nil
+}
+
+// bitsOf returns a function reporting whether a bit of v is set,
+// v should be an integral number or BinData, and numbers are sign extended.
+func bitsOf(v interface{}) (func(pos int64) bool, bool) {
+	if bin, ok := v.(primitive.Binary); ok {
+		return func(pos int64) bool {
+			i := pos / 8
+			return i < int64(len(bin.Data)) && bin.Data[i]&(1<<(pos%8)) != 0
+		}, true
+	}
+	n, ok := integral(v)
+	if !ok {
+		return nil, false
+	}
+	return func(pos int64) bool {
+		if pos > 63 {
+			pos = 63
+		}
+		return uint64(n)&(1<<pos) != 0
+	}, true
+}
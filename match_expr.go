@@ -0,0 +1,352 @@
+package builder
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// evalExpr evaluates an aggregation expression against root, see Expression for supported operators.
+// Missing fields are evaluated to the missing value, which is less than null in comparisons.
+func evalExpr(v interface{}, root bson.D) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		switch {
+		case v == "$$ROOT" || v == "$$CURRENT":
+			return root, nil
+		case strings.HasPrefix(v, "$$"):
+			return nil, fmt.Errorf("filterBuilder: variable %s is not supported by Match", v)
+		case strings.HasPrefix(v, "$"):
+			return exprPath(root, strings.Split(v[1:], ".")), nil
+		}
+		return v, nil
+	case bson.D:
+		if len(v) == 1 && strings.HasPrefix(v[0].Key, "$") {
+			return evalOp(v[0].Key, v[0].Value, root)
+		}
+		d := make(bson.D, 0, len(v))
+		for _, e := range v {
+			val, err := evalExpr(e.Value, root)
+			if err != nil {
+				return nil, err
+			}
+			if val != missing {
+				d = append(d, bson.E{Key: e.Key, Value: val})
+			}
+		}
+		return d, nil
+	case bson.A:
+		return evalArgs(v, root)
+	}
+	return v, nil
+}
+
+// exprPath resolves a field path in expressions,
+// arrays are traversed and result in arrays of the values of their elements.
+// Nested arrays are kept as nested arrays, and scalar elements are skipped.
+func exprPath(v interface{}, path []string) interface{} {
+	if len(path) == 0 {
+		return v
+	}
+	switch v := v.(type) {
+	case bson.D:
+		field, ok := docGet(v, path[0])
+		if !ok {
+			return missing
+		}
+		return exprPath(field, path[1:])
+	case bson.A:
+		res := bson.A{}
+		for _, elem := range v {
+			switch elem.(type) {
+			case bson.D, bson.A:
+				if val := exprPath(elem, path); val != missing {
+					res = append(res, val)
+				}
+			}
+		}
+		return res
+	}
+	return missing
+}
+
+// evalArgs evaluates each argument.
+func evalArgs(args bson.A, root bson.D) (bson.A, error) {
+	res := make(bson.A, 0, len(args))
+	for _, a := range args {
+		v, err := evalExpr(a, root)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, v)
+	}
+	return res, nil
+}
+
+// evalOp evaluates the operator expression `{op: arg}`.
+func evalOp(op string, arg interface{}, root bson.D) (interface{}, error) {
+	switch op {
+	case _literal:
+		return arg, nil
+	case _dateDiff:
+		return evalDateDiff(arg, root)
+	}
+
+	rawArgs, ok := arg.(bson.A)
+	if !ok {
+		rawArgs = bson.A{arg}
+	}
+	args, err := evalArgs(rawArgs, root)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case _eq, _ne, _gt, _gte, _lt, _lte:
+		if len(args) != 2 {
+			return nil, fmt.Errorf("filterBuilder: %s takes 2 arguments, got %d", op, len(args))
+		}
+		cmp := compareNormalized(args[0], args[1])
+		switch op {
+		case _eq:
+			return cmp == 0, nil
+		case _ne:
+			return cmp != 0, nil
+		case _gt:
+			return cmp > 0, nil
+		case _gte:
+			return cmp >= 0, nil
+		case _lt:
+			return cmp < 0, nil
+		}
+		return cmp <= 0, nil
+	case _and:
+		for _, a := range args {
+			if !truthy(a) {
+				return false, nil
+			}
+		}
+		return true, nil
+	case _or:
+		for _, a := range args {
+			if truthy(a) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case _add, _subtract, _multiply, _divide:
+		return evalArith(op, args)
+	case _strLenCP:
+		if len(args) != 1 {
+			return nil, fmt.Errorf("filterBuilder: %s takes 1 argument, got %d", op, len(args))
+		}
+		s, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("filterBuilder: %s requires a string, got %T", op, args[0])
+		}
+		return int32(utf8.RuneCountInString(s)), nil
+	case _size:
+		if len(args) != 1 {
+			return nil, fmt.Errorf("filterBuilder: %s takes 1 argument, got %d", op, len(args))
+		}
+		arr, ok := args[0].(bson.A)
+		if !ok {
+			return nil, fmt.Errorf("filterBuilder: %s requires an array, got %T", op, args[0])
+		}
+		return int32(len(arr)), nil
+	}
+	return nil, fmt.Errorf("filterBuilder: expression operator %s is not supported by Match", op)
+}
+
+// truthy reports whether v is true in expressions, false, null, missing and zero are false.
+func truthy(v interface{}) bool {
+	switch v := v.(type) {
+	case nil, missingValue, primitive.Null, primitive.Undefined:
+		return false
+	case bool:
+		return v
+	case int32, int64, float64, primitive.Decimal128:
+		f, _ := toFloat(v)
+		return f != 0
+	}
+	return true
+}
+
+// isNullish reports whether v is null or missing.
+func isNullish(v interface{}) bool {
+	switch v.(type) {
+	case nil, missingValue, primitive.Null, primitive.Undefined:
+		return true
+	}
+	return false
+}
+
+// evalArith evaluates arithmetic operators, the result is null if any argument is null or missing.
+//
+// Integers are calculated as int64, and dates can be added or subtracted by milliseconds.
+func evalArith(op string, args bson.A) (interface{}, error) {
+	if (op == _subtract || op == _divide) && len(args) != 2 {
+		return nil, fmt.Errorf("filterBuilder: %s takes 2 arguments, got %d", op, len(args))
+	}
+	for _, a := range args {
+		if isNullish(a) {
+			return nil, nil
+		}
+	}
+
+	if op == _subtract {
+		if start, ok := args[0].(primitive.DateTime); ok {
+			if end, ok := args[1].(primitive.DateTime); ok {
+				return int64(start) - int64(end), nil
+			}
+			ms, ok := integral(args[1])
+			if !ok {
+				return nil, fmt.Errorf("filterBuilder: can't subtract %T from a date", args[1])
+			}
+			return primitive.DateTime(int64(start) - ms), nil
+		}
+	}
+
+	var date *primitive.DateTime
+	allInt := op != _divide
+	for i, a := range args {
+		if d, ok := a.(primitive.DateTime); ok && op == _add && date == nil {
+			date = &d
+			args[i] = int64(d)
+			continue
+		}
+		if typeOrder(a) != 3 {
+			return nil, fmt.Errorf("filterBuilder: %s only supports numbers, got %T", op, a)
+		}
+		if _, ok := a.(float64); ok {
+			allInt = false
+		}
+		if _, ok := a.(primitive.Decimal128); ok {
+			allInt = false
+		}
+	}
+
+	if allInt {
+		res, _ := integral(args[0])
+		for _, a := range args[1:] {
+			n, _ := integral(a)
+			switch op {
+			case _add:
+				res += n
+			case _subtract:
+				res -= n
+			case _multiply:
+				res *= n
+			}
+		}
+		if date != nil {
+			return primitive.DateTime(res), nil
+		}
+		return res, nil
+	}
+
+	res, _ := toFloat(args[0])
+	for _, a := range args[1:] {
+		n, _ := toFloat(a)
+		switch op {
+		case _add:
+			res += n
+		case _subtract:
+			res -= n
+		case _multiply:
+			res *= n
+		case _divide:
+			if n == 0 {
+				return nil, fmt.Errorf("filterBuilder: %s by zero", op)
+			}
+			res /= n
+		}
+	}
+	if date != nil {
+		return primitive.DateTime(int64(math.Round(res))), nil
+	}
+	return res, nil
+}
+
+// evalDateDiff evaluates `$dateDiff: {startDate, endDate, unit}` in UTC, weeks start on Sunday.
+func evalDateDiff(arg interface{}, root bson.D) (interface{}, error) {
+	d, ok := arg.(bson.D)
+	if !ok {
+		return nil, fmt.Errorf("filterBuilder: %s should be a document, got %T", _dateDiff, arg)
+	}
+	vals := map[string]interface{}{}
+	for _, e := range d {
+		switch e.Key {
+		case "startDate", "endDate", "unit":
+		default:
+			return nil, fmt.Errorf("filterBuilder: %s.%s is not supported by Match", _dateDiff, e.Key)
+		}
+		v, err := evalExpr(e.Value, root)
+		if err != nil {
+			return nil, err
+		}
+		vals[e.Key] = v
+	}
+	if isNullish(vals["startDate"]) || isNullish(vals["endDate"]) || isNullish(vals["unit"]) {
+		return nil, nil
+	}
+	start, ok1 := vals["startDate"].(primitive.DateTime)
+	end, ok2 := vals["endDate"].(primitive.DateTime)
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("filterBuilder: startDate and endDate of %s should be dates", _dateDiff)
+	}
+	unit, _ := vals["unit"].(string)
+
+	s, e := start.Time().UTC(), end.Time().UTC()
+	switch unit {
+	case "year":
+		return int64(e.Year() - s.Year()), nil
+	case "quarter":
+		return int64(quarterOf(e) - quarterOf(s)), nil
+	case "month":
+		return int64(monthOf(e) - monthOf(s)), nil
+	case "week":
+		// 1970-01-01 is a Thursday, which is the 4th day of a week starting on Sunday
+		return floorDiv(daysOf(e)+4, 7) - floorDiv(daysOf(s)+4, 7), nil
+	case "day":
+		return daysOf(e) - daysOf(s), nil
+	case "hour":
+		return floorDiv(int64(end), int64(time.Hour/time.Millisecond)) - floorDiv(int64(start), int64(time.Hour/time.Millisecond)), nil
+	case "minute":
+		return floorDiv(int64(end), int64(time.Minute/time.Millisecond)) - floorDiv(int64(start), int64(time.Minute/time.Millisecond)), nil
+	case "second":
+		return floorDiv(int64(end), 1000) - floorDiv(int64(start), 1000), nil
+	case "millisecond":
+		return int64(end) - int64(start), nil
+	}
+	return nil, fmt.Errorf("filterBuilder: unknown unit %q of %s", unit, _dateDiff)
+}
+
+// floorDiv divides a by b and rounds towards negative infinity.
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
+
+// daysOf returns the number of days since 1970-01-01.
+func daysOf(t time.Time) int64 {
+	return floorDiv(t.Unix(), 24*60*60)
+}
+
+// monthOf returns the number of months since year 0.
+func monthOf(t time.Time) int {
+	return t.Year()*12 + int(t.Month()) - 1
+}
+
+// quarterOf returns the number of quarters since year 0.
+func quarterOf(t time.Time) int {
+	return t.Year()*4 + (int(t.Month())-1)/3
+}
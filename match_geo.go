@@ -0,0 +1,348 @@
+package builder
+
+import (
+	"fmt"
+	"math"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// earthRadius is the radius of the earth in meters used by MongoDB.
+const earthRadius = 6378100.0
+
+// shape is a geometry of a document or a query,
+// points are polygons of a single vertex without rings.
+type shape struct {
+	point    *Point
+	polygons []Polygon
+}
+
+// matchGeo evaluates geospatial operators against the values of a field.
+func matchGeo(vals []interface{}, op string, arg interface{}) (bool, error) {
+	query, ok := arg.(bson.D)
+	if !ok {
+		return false, fmt.Errorf("filterBuilder: %s should be a document, got %T", op, arg)
+	}
+	var match func(s shape) bool
+	switch op {
+	case _near, _nearSphere:
+		center, minDist, maxDist, err := nearQuery(op, query)
+		if err != nil {
+			return false, err
+		}
+		match = func(s shape) bool {
+			if s.point == nil {
+				return false
+			}
+			dist := sphereDistance(*s.point, center) * earthRadius
+			return dist >= minDist && (maxDist < 0 || dist <= maxDist)
+		}
+	case _geoWithin:
+		within, err := withinQuery(query)
+		if err != nil {
+			return false, err
+		}
+		match = within
+	case _geoIntersects:
+		g, ok := docGet(query, _geometry)
+		if !ok {
+			return false, fmt.Errorf("filterBuilder: %s requires %s", op, _geometry)
+		}
+		target, ok := shapeOf(g)
+		if !ok {
+			return false, fmt.Errorf("filterBuilder: invalid %s of %s", _geometry, op)
+		}
+		match = func(s shape) bool {
+			return intersects(s, target)
+		}
+	}
+
+	// arrays which are not legacy coordinate pairs are arrays of shapes
+	return anyExpanded(vals, func(v interface{}) (bool, error) {
+		s, ok := shapeOf(v)
+		return ok && match(s), nil
+	})
+}
+
+// nearQuery parses the query of `$near` and `$nearSphere`, maxDist is negative if it's not given.
+func nearQuery(op string, query bson.D) (center Point, minDist, maxDist float64, err error) {
+	g, ok := docGet(query, _geometry)
+	if !ok {
+		return center, 0, 0, fmt.Errorf("filterBuilder: %s requires %s", op, _geometry)
+	}
+	s, ok := shapeOf(g)
+	if !ok || s.point == nil {
+		return center, 0, 0, fmt.Errorf("filterBuilder: %s of %s should be a point", _geometry, op)
+	}
+	maxDist = -1
+	if v, ok := docGet(query, _minDistance); ok {
+		minDist, _ = toFloat(v)
+	}
+	if v, ok := docGet(query, _maxDistance); ok {
+		maxDist, _ = toFloat(v)
+	}
+	return *s.point, minDist, maxDist, nil
+}
+
+// withinQuery parses the query of `$geoWithin`.
+func withinQuery(query bson.D) (func(s shape) bool, error) {
+	if len(query) != 1 {
+		return nil, fmt.Errorf("filterBuilder: %s should have a single shape", _geoWithin)
+	}
+	switch e := query[0]; e.Key {
+	case _geometry:
+		target, ok := shapeOf(e.Value)
+		if !ok || target.point != nil {
+			return nil, fmt.Errorf("filterBuilder: %s of %s should be a polygon", _geometry, _geoWithin)
+		}
+		return func(s shape) bool {
+			for _, p := range s.vertices() {
+				if !target.contains(p) {
+					return false
+				}
+			}
+			return true
+		}, nil
+	case _box:
+		corners, ok := pointList(e.Value)
+		if !ok || len(corners) != 2 {
+			return nil, fmt.Errorf("filterBuilder: %s should be 2 points", _box)
+		}
+		return func(s shape) bool {
+			for _, p := range s.vertices() {
+				if p[0] < corners[0][0] || p[0] > corners[1][0] || p[1] < corners[0][1] || p[1] > corners[1][1] {
+					return false
+				}
+			}
+			return true
+		}, nil
+	case _centerSphere:
+		arr, ok := e.Value.(bson.A)
+		if !ok || len(arr) != 2 {
+			return nil, fmt.Errorf("filterBuilder: %s should be [center, radius]", _centerSphere)
+		}
+		center, ok1 := pointOf(arr[0])
+		radius, ok2 := toFloat(arr[1])
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("filterBuilder: %s should be [center, radius]", _centerSphere)
+		}
+		return func(s shape) bool {
+			for _, p := range s.vertices() {
+				if sphereDistance(p, center) > radius {
+					return false
+				}
+			}
+			return true
+		}, nil
+	}
+	return nil, fmt.Errorf("filterBuilder: %s of %s is not supported by Match", query[0].Key, _geoWithin)
+}
+
+// shapeOf converts a GeoJSON Point, Polygon, MultiPolygon or a legacy coordinate pair to a shape.
+func shapeOf(v interface{}) (shape, bool) {
+	if p, ok := pointOf(v); ok {
+		return shape{point: &p}, true
+	}
+	d, ok := v.(bson.D)
+	if !ok {
+		return shape{}, false
+	}
+	typ, _ := docGet(d, "type")
+	coords, _ := docGet(d, "coordinates")
+	switch typ {
+	case "Point":
+		if p, ok := pointOf(coords); ok {
+			return shape{point: &p}, true
+		}
+	case "Polygon":
+		if p, ok := polygonOf(coords); ok {
+			return shape{polygons: []Polygon{p}}, true
+		}
+	case "MultiPolygon":
+		arr, ok := coords.(bson.A)
+		if !ok {
+			return shape{}, false
+		}
+		s := shape{}
+		for _, c := range arr {
+			p, ok := polygonOf(c)
+			if !ok {
+				return shape{}, false
+			}
+			s.polygons = append(s.polygons, p)
+		}
+		return s, true
+	}
+	return shape{}, false
+}
+
+// pointOf converts [lng, lat] to a Point.
+func pointOf(v interface{}) (Point, bool) {
+	arr, ok := v.(bson.A)
+	if !ok || len(arr) != 2 {
+		return Point{}, false
+	}
+	lng, ok1 := toFloat(arr[0])
+	lat, ok2 := toFloat(arr[1])
+	return Point{lng, lat}, ok1 && ok2
+}
+
+// pointList converts an array of [lng, lat] to points.
+func pointList(v interface{}) ([]Point, bool) {
+	arr, ok := v.(bson.A)
+	if !ok {
+		return nil, false
+	}
+	res := make([]Point, 0, len(arr))
+	for _, e := range arr {
+		p, ok := pointOf(e)
+		if !ok {
+			return nil, false
+		}
+		res = append(res, p)
+	}
+	return res, true
+}
+
+// polygonOf converts the coordinates of a GeoJSON polygon to a Polygon.
+func polygonOf(v interface{}) (Polygon, bool) {
+	arr, ok := v.(bson.A)
+	if !ok || len(arr) == 0 {
+		return nil, false
+	}
+	p := make(Polygon, 0, len(arr))
+	for _, r := range arr {
+		ring, ok := pointList(r)
+		if !ok {
+			return nil, false
+		}
+		p = append(p, ring)
+	}
+	return p, true
+}
+
+// vertices returns the point or the vertices of exterior rings of the shape.
+func (s shape) vertices() []Point {
+	if s.point != nil {
+		return []Point{*s.point}
+	}
+	res := []Point{}
+	for _, p := range s.polygons {
+		res = append(res, p[0]...)
+	}
+	return res
+}
+
+// edges returns the edges of all rings of the shape.
+func (s shape) edges() [][2]Point {
+	res := [][2]Point{}
+	for _, p := range s.polygons {
+		for _, ring := range p {
+			for i := 0; i+1 < len(ring); i++ {
+				res = append(res, [2]Point{ring[i], ring[i+1]})
+			}
+		}
+	}
+	return res
+}
+
+// contains reports whether the point p is in the polygons of the shape, or equals to the point of the shape.
+func (s shape) contains(p Point) bool {
+	if s.point != nil {
+		return *s.point == p
+	}
+	for _, poly := range s.polygons {
+		if !ringContains(poly[0], p) {
+			continue
+		}
+		inHole := false
+		for _, hole := range poly[1:] {
+			if ringContains(hole, p) && !onRing(hole, p) {
+				inHole = true
+				break
+			}
+		}
+		if !inHole {
+			return true
+		}
+	}
+	return false
+}
+
+// intersects reports whether two shapes share any point.
+func intersects(a, b shape) bool {
+	for _, p := range a.vertices() {
+		if b.contains(p) {
+			return true
+		}
+	}
+	for _, p := range b.vertices() {
+		if a.contains(p) {
+			return true
+		}
+	}
+	for _, x := range a.edges() {
+		for _, y := range b.edges() {
+			if segmentsIntersect(x[0], x[1], y[0], y[1]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ringContains reports whether p is inside or on the ring with the ray casting algorithm.
+func ringContains(ring []Point, p Point) bool {
+	if onRing(ring, p) {
+		return true
+	}
+	in := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a[1] > p[1]) != (b[1] > p[1]) && p[0] < (b[0]-a[0])*(p[1]-a[1])/(b[1]-a[1])+a[0] {
+			in = !in
+		}
+	}
+	return in
+}
+
+// onRing reports whether p is on an edge of the ring.
+func onRing(ring []Point, p Point) bool {
+	for i := 0; i+1 < len(ring); i++ {
+		if cross(ring[i], ring[i+1], p) == 0 && inSegmentBox(ring[i], ring[i+1], p) {
+			return true
+		}
+	}
+	return false
+}
+
+// cross returns the cross product of ab and ac.
+func cross(a, b, c Point) float64 {
+	return (b[0]-a[0])*(c[1]-a[1]) - (b[1]-a[1])*(c[0]-a[0])
+}
+
+// inSegmentBox reports whether p is in the bounding box of segment ab.
+func inSegmentBox(a, b, p Point) bool {
+	return math.Min(a[0], b[0]) <= p[0] && p[0] <= math.Max(a[0], b[0]) &&
+		math.Min(a[1], b[1]) <= p[1] && p[1] <= math.Max(a[1], b[1])
+}
+
+// segmentsIntersect reports whether segment ab and cd share any point.
+func segmentsIntersect(a, b, c, d Point) bool {
+	d1, d2 := cross(c, d, a), cross(c, d, b)
+	d3, d4 := cross(a, b, c), cross(a, b, d)
+	if ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) && ((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0)) {
+		return true
+	}
+	return d1 == 0 && inSegmentBox(c, d, a) || d2 == 0 && inSegmentBox(c, d, b) ||
+		d3 == 0 && inSegmentBox(a, b, c) || d4 == 0 && inSegmentBox(a, b, d)
+}
+
+// sphereDistance returns the great-circle distance between two points in radians.
+func sphereDistance(a, b Point) float64 {
+	toRad := math.Pi / 180
+	lat1, lat2 := a[1]*toRad, b[1]*toRad
+	dLat, dLng := lat2-lat1, (b[0]-a[0])*toRad
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * math.Asin(math.Min(1, math.Sqrt(h)))
+}
@@ -0,0 +1,213 @@
+package builder
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// matchSchema reports whether v is valid against the `$jsonSchema` schema.
+//
+// The keywords generated by JSONSchemaFromStruct are supported: bsonType, required, properties,
+// additionalProperties, minimum, maximum, minLength, maxLength, minItems, maxItems, enum and items.
+func matchSchema(schema bson.D, v interface{}) (bool, error) {
+	for _, e := range schema {
+		var (
+			ok  = true
+			err error
+		)
+		switch e.Key {
+		case "bsonType":
+			ok, err = matchBSONType(v, e.Value)
+		case "required":
+			ok, err = matchRequired(v, e.Value)
+		case "properties":
+			ok, err = matchProperties(v, e.Value)
+		case "additionalProperties":
+			ok, err = matchAdditionalProperties(v, e.Value, schema)
+		case "minimum", "maximum":
+			ok, err = matchBound(e.Key, v, e.Value)
+		case "minLength", "maxLength":
+			if s, isStr := v.(string); isStr {
+				ok, err = matchLength(e.Key, int64(utf8.RuneCountInString(s)), e.Value)
+			}
+		case "minItems", "maxItems":
+			if arr, isArr := v.(bson.A); isArr {
+				ok, err = matchLength(e.Key, int64(len(arr)), e.Value)
+			}
+		case "enum":
+			list, isArr := e.Value.(bson.A)
+			if !isArr {
+				return false, fmt.Errorf("filterBuilder: enum should be an array, got %T", e.Value)
+			}
+			ok = false
+			for _, a := range list {
+				if equalValues(v, a) {
+					ok = true
+					break
+				}
+			}
+		case "items":
+			ok, err = matchItems(v, e.Value)
+		case "title", "description":
+		default:
+			return false, fmt.Errorf("filterBuilder: %s keyword %q is not supported by Match", _jsonSchema, e.Key)
+		}
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// matchBSONType reports whether the type of v is one of the type aliases.
+func matchBSONType(v, types interface{}) (bool, error) {
+	if arr, ok := types.(bson.A); ok {
+		for _, t := range arr {
+			if _, ok := t.(string); !ok {
+				return false, fmt.Errorf("filterBuilder: bsonType should be aliases, got %v", t)
+			}
+		}
+		return matchType(v, arr)
+	}
+	if _, ok := types.(string); !ok {
+		return false, fmt.Errorf("filterBuilder: bsonType should be aliases, got %v", types)
+	}
+	return matchType(v, types)
+}
+
+// matchRequired reports whether the document v has all required fields.
+func matchRequired(v, required interface{}) (bool, error) {
+	d, ok := v.(bson.D)
+	if !ok {
+		return true, nil
+	}
+	list, ok := required.(bson.A)
+	if !ok {
+		return false, fmt.Errorf("filterBuilder: required should be an array, got %T", required)
+	}
+	for _, r := range list {
+		key, ok := r.(string)
+		if !ok {
+			return false, fmt.Errorf("filterBuilder: required should be field names, got %v", r)
+		}
+		if _, ok := docGet(d, key); !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// matchProperties validates fields of the document v.
+func matchProperties(v, properties interface{}) (bool, error) {
+	d, ok := v.(bson.D)
+	if !ok {
+		return true, nil
+	}
+	props, ok := properties.(bson.D)
+	if !ok {
+		return false, fmt.Errorf("filterBuilder: properties should be a document, got %T", properties)
+	}
+	for _, p := range props {
+		field, ok := docGet(d, p.Key)
+		if !ok {
+			continue
+		}
+		schema, ok := p.Value.(bson.D)
+		if !ok {
+			return false, fmt.Errorf("filterBuilder: schema of property %s should be a document, got %T", p.Key, p.Value)
+		}
+		if ok, err := matchSchema(schema, field); err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// matchAdditionalProperties validates fields of the document v which are not declared in properties.
+func matchAdditionalProperties(v, additional interface{}, schema bson.D) (bool, error) {
+	d, ok := v.(bson.D)
+	if !ok {
+		return true, nil
+	}
+	props, _ := docGet(schema, "properties")
+	declared, _ := props.(bson.D)
+	for _, e := range d {
+		if _, ok := docGet(declared, e.Key); ok {
+			continue
+		}
+		switch a := additional.(type) {
+		case bool:
+			if !a {
+				return false, nil
+			}
+		case bson.D:
+			if ok, err := matchSchema(a, e.Value); err != nil || !ok {
+				return false, err
+			}
+		default:
+			return false, fmt.Errorf("filterBuilder: additionalProperties should be a bool or a document, got %T", additional)
+		}
+	}
+	return true, nil
+}
+
+// matchBound validates `minimum` and `maximum` of numbers.
+func matchBound(keyword string, v, bound interface{}) (bool, error) {
+	if typeOrder(bound) != 3 {
+		return false, fmt.Errorf("filterBuilder: %s should be a number, got %T", keyword, bound)
+	}
+	if typeOrder(v) != 3 {
+		return true, nil
+	}
+	cmp := compareNormalized(v, bound)
+	if keyword == "minimum" {
+		return cmp >= 0, nil
+	}
+	return cmp <= 0, nil
+}
+
+// matchLength validates lengths of strings and arrays.
+func matchLength(keyword string, length int64, bound interface{}) (bool, error) {
+	n, ok := integral(bound)
+	if !ok {
+		return false, fmt.Errorf("filterBuilder: %s should be an integer, got %v", keyword, bound)
+	}
+	if keyword == "minLength" || keyword == "minItems" {
+		return length >= n, nil
+	}
+	return length <= n, nil
+}
+
+// matchItems validates elements of the array v.
+func matchItems(v, items interface{}) (bool, error) {
+	arr, ok := v.(bson.A)
+	if !ok {
+		return true, nil
+	}
+	switch s := items.(type) {
+	case bson.D:
+		for _, elem := range arr {
+			if ok, err := matchSchema(s, elem); err != nil || !ok {
+				return false, err
+			}
+		}
+	case bson.A:
+		for i, elem := range arr {
+			if i >= len(s) {
+				break
+			}
+			schema, ok := s[i].(bson.D)
+			if !ok {
+				return false, fmt.Errorf("filterBuilder: items should be schemas, got %T", s[i])
+			}
+			if ok, err := matchSchema(schema, elem); err != nil || !ok {
+				return false, err
+			}
+		}
+	default:
+		return false, fmt.Errorf("filterBuilder: items should be a document or an array, got %T", items)
+	}
+	return true, nil
+}
@@ -0,0 +1,435 @@
+package builder_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	builder "github.com/JsyTech/mongo-filter-builder"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMatch(t *testing.T) {
+	day := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	doc := bson.M{
+		"name":    "Volinda",
+		"age":     int32(20),
+		"score":   88.5,
+		"tags":    bson.A{"a", "b"},
+		"created": day,
+		"deleted": nil,
+		"flags":   int32(0b1010),
+		"items": bson.A{
+			bson.M{"sku": "x-1", "qty": 1},
+			bson.M{"sku": "x-2", "qty": 5},
+		},
+		"location": bson.M{"type": "Point", "coordinates": bson.A{-73.97, 40.77}},
+	}
+
+	cases := []struct {
+		name   string
+		filter bson.M
+		match  bool
+	}{
+		{"eq", builder.New().Str("name").Eq("Volinda").Build(), true},
+		{"eq across number types", builder.New().Num("age").Eq(20.0).Build(), true},
+		{"literal", bson.M{"name": "Volinda", "age": 20}, true},
+		{"ne", builder.New().Str("name").Ne("Volinda").Build(), false},
+		{"range", builder.New().Num("age").Between(18, 30).Build(), true},
+		{"type bracketing", bson.M{"name": bson.M{"$gt": 1}}, false},
+		{"date", builder.New().Date("created").Gte(day).Date("created").Lt(day.AddDate(0, 0, 1)).Build(), true},
+		{"in", builder.New().Str("name").In("a", "Volinda").Build(), true},
+		{"in regex", bson.M{"name": bson.M{"$in": bson.A{primitive.Regex{Pattern: "^vol", Options: "i"}}}}, true},
+		{"nin", builder.New().Str("name").Nin("Volinda").Build(), false},
+		{"in array", builder.New().Arr("tags").ContainsAny("b", "c").Build(), true},
+		{"eq array element", bson.M{"tags": "a"}, true},
+		{"eq whole array", bson.M{"tags": bson.A{"a", "b"}}, true},
+		{"all", builder.New().Arr("tags").All("a", "b").Build(), true},
+		{"all missing", builder.New().Arr("tags").All("a", "c").Build(), false},
+		{"size", builder.New().Arr("tags").Size(2).Build(), true},
+		{"not empty", builder.New().Arr("tags").NotEmpty().Build(), true},
+		{"empty", builder.New().Arr("tags").Empty().Build(), false},
+		{"regex", builder.New().Str("name").RegexWithOpt("^vol", "i").Build(), true},
+		{"regex case", builder.New().Str("name").Regex("^vol").Build(), false},
+		{"not regex", builder.New().Str("name").Not("^vol").Build(), true},
+		{"negate", builder.New().Num("age").Negate().Gt(30).Build(), true},
+		{"negate missing", builder.New().Num("missing").Negate().Gt(30).Build(), true},
+		{"exists", builder.New().Field("deleted").IsNull().Build(), true},
+		{"missing", builder.New().Field("nothing").IsMissing().Build(), true},
+		{"null or missing", builder.New().Field("nothing").IsNullOrMissing().Build(), true},
+		{"not null", builder.New().Field("deleted").NotNull().Build(), false},
+		{"type", builder.New().Any("age").TypeAlias("number").Build(), true},
+		{"type code", builder.New().Any("score").Type(bson.TypeDouble).Build(), true},
+		{"dotted path", bson.M{"items.sku": "x-2"}, true},
+		{"array index", bson.M{"items.1.qty": 5}, true},
+		{"dotted path null", bson.M{"items.color": nil}, true},
+		{"elemMatch", builder.New().Arr("items").ElemMatch(func(e *builder.Builder) {
+			e.Str("sku").Eq("x-1").Num("qty").Gte(2)
+		}).Build(), false},
+		{"without elemMatch", bson.M{"items.sku": "x-1", "items.qty": bson.M{"$gte": 2}}, true},
+		{"scalar elemMatch", builder.New().Arr("tags").ElemMatch(func(e *builder.Builder) {
+			e.Str("").In("b")
+		}).Build(), true},
+		{"or", builder.New().Str("name").Eq("a").Or().Num("age").Lt(30).Build(), true},
+		{"nor", builder.New().NorGroup(func(g *builder.Builder) {
+			g.Str("name").Eq("a").Or().Num("age").Lt(30)
+		}).Build(), false},
+		{"and group", builder.New().AndGroup(func(g *builder.Builder) {
+			g.Str("name").Eq("a").Or().Num("age").Lt(30)
+		}).Build(), true},
+		{"mod", builder.New().Num("age").Mod(3, 2).Build(), true},
+		{"bits all set", builder.New().Num("flags").BitsAllSet([]int{1, 3}).Build(), true},
+		{"bits any set", builder.New().Num("flags").BitsAnySet(0b0101).Build(), false},
+		{"bits all clear", builder.New().Num("flags").BitsAllClear(0b0101).Build(), true},
+		{"bits any clear", builder.New().Num("flags").BitsAnyClear([]int{1, 3}).Build(), false},
+		{"expr", builder.New().Expr(builder.Field("age").Add(builder.Lit(10)).Gt(builder.Lit(25))).Build(), true},
+		{"expr strLenCP", builder.New().Expr(builder.Field("name").StrLenCP().Eq(builder.Lit(7))).Build(), true},
+		{"expr size", builder.New().Expr(builder.Field("tags").Size().Lt(builder.Lit(2))).Build(), false},
+		{"expr dateDiff", builder.New().Expr(builder.Field("created").DateDiff(builder.Lit(day.AddDate(0, 2, 0)), "month").Eq(builder.Lit(2))).Build(), true},
+		{"near", builder.New().Geo("location").NearSphere(builder.NewPoint(-73.98, 40.77), 0, 1000).Build(), true},
+		{"near too far", builder.New().Geo("location").Near(builder.NewPoint(-73.9, 40.77), 0, 1000).Build(), false},
+		{"within box", builder.New().Geo("location").WithinBox(builder.NewPoint(-74, 40), builder.NewPoint(-73, 41)).Build(), true},
+		{"within center sphere", builder.New().Geo("location").WithinCenterSphere(builder.NewPoint(-73.97, 40.7), 10/6378.1).Build(), true},
+		{"within polygon", builder.New().Geo("location").WithinPolygon(builder.Polygon{{
+			{-74, 40}, {-73, 40}, {-73, 41}, {-74, 41}, {-74, 40},
+		}}).Build(), true},
+		{"intersects", builder.New().Geo("location").Intersects(builder.Polygon{{
+			{-75, 40}, {-74, 40}, {-74, 41}, {-75, 41}, {-75, 40},
+		}}).Build(), false},
+	}
+	for _, c := range cases {
+		ok, err := builder.Match(c.filter, doc)
+		assert.Nil(t, err, c.name)
+		assert.Equal(t, c.match, ok, c.name)
+	}
+}
+
+func TestMatch_Struct(t *testing.T) {
+	type user struct {
+		Name string `bson:"name" schema:"required"`
+		Age  int    `bson:"age" schema:"min=0,max=150"`
+	}
+
+	ok, err := builder.Match(builder.New().Auto(user{Name: "a"}).Build(), user{Name: "a", Age: 20})
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	schema := builder.New().MatchesSchema(user{}, nil).Build()
+	ok, err = builder.Match(schema, user{Name: "a", Age: 20})
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = builder.Match(schema, user{Name: "a", Age: 200})
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	ok, err = builder.Match(schema, bson.M{"age": 1})
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
+
+func TestMatch_Error(t *testing.T) {
+	_, err := builder.Match(builder.New().Text("coffee").Build(), bson.M{})
+	assert.NotNil(t, err)
+
+	_, err = builder.Match(bson.M{"a": bson.M{"$unknown": 1}}, bson.M{})
+	assert.NotNil(t, err)
+
+	_, err = builder.Match(bson.M{"a": bson.M{"$mod": bson.A{0, 1}}}, bson.M{"a": 1})
+	assert.NotNil(t, err)
+
+	_, err = builder.Match(bson.M{"a": bson.M{"$bitsAnySet": int64(math.MaxInt32) + 1}}, bson.M{"a": 1})
+	assert.NotNil(t, err)
+
+	ok, err := builder.Match(bson.M{"a": bson.M{"$bitsAllSet": math.MaxInt32}}, bson.M{"a": int32(math.MaxInt32)})
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	_, err = builder.Match(bson.M{}, 1)
+	assert.NotNil(t, err)
+}
+
+func TestMatch_Geo(t *testing.T) {
+	square := builder.Polygon{{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}}}
+	holed := builder.Polygon{
+		{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}},
+		{{4, 4}, {6, 4}, {6, 6}, {4, 6}, {4, 4}},
+	}
+	point := func(lng, lat float64) bson.M {
+		return bson.M{"type": "Point", "coordinates": bson.A{lng, lat}}
+	}
+	polygon := func(ring ...bson.A) bson.M {
+		coords := bson.A{}
+		for _, p := range ring {
+			coords = append(coords, p)
+		}
+		return bson.M{"type": "Polygon", "coordinates": bson.A{coords}}
+	}
+
+	cases := []struct {
+		name   string
+		filter bson.M
+		doc    bson.M
+		match  bool
+	}{
+		{"within polygon", builder.New().Geo("loc").WithinPolygon(holed).Build(), bson.M{"loc": point(2, 2)}, true},
+		{"within hole", builder.New().Geo("loc").WithinPolygon(holed).Build(), bson.M{"loc": point(5, 5)}, false},
+		{"on hole edge", builder.New().Geo("loc").WithinPolygon(holed).Build(), bson.M{"loc": point(4, 5)}, true},
+		{"on exterior edge", builder.New().Geo("loc").WithinPolygon(square).Build(), bson.M{"loc": point(10, 5)}, true},
+		{"outside polygon", builder.New().Geo("loc").WithinPolygon(square).Build(), bson.M{"loc": point(11, 5)}, false},
+		{"polygon within polygon", builder.New().Geo("loc").WithinPolygon(square).Build(),
+			bson.M{"loc": polygon(bson.A{1, 1}, bson.A{2, 1}, bson.A{2, 2}, bson.A{1, 1})}, true},
+		{"polygon partly within polygon", builder.New().Geo("loc").WithinPolygon(square).Build(),
+			bson.M{"loc": polygon(bson.A{9, 9}, bson.A{12, 9}, bson.A{12, 12}, bson.A{9, 9})}, false},
+		{"legacy pair", builder.New().Geo("loc").WithinBox(builder.NewPoint(0, 0), builder.NewPoint(1, 1)).Build(),
+			bson.M{"loc": bson.A{0.5, 0.5}}, true},
+		{"box corner", builder.New().Geo("loc").WithinBox(builder.NewPoint(0, 0), builder.NewPoint(1, 1)).Build(),
+			bson.M{"loc": bson.A{1, 1}}, true},
+		{"intersects point in polygon", builder.New().Geo("loc").Intersects(square).Build(), bson.M{"loc": point(5, 5)}, true},
+		{"intersects point in hole", builder.New().Geo("loc").Intersects(holed).Build(), bson.M{"loc": point(5, 5)}, false},
+		{"intersects point on vertex", builder.New().Geo("loc").Intersects(square).Build(), bson.M{"loc": point(0, 0)}, true},
+		{"intersects point", builder.New().Geo("loc").Intersects(builder.NewPoint(1, 2)).Build(), bson.M{"loc": point(1, 2)}, true},
+		{"intersects crossing edges", builder.New().Geo("loc").Intersects(square).Build(),
+			bson.M{"loc": polygon(bson.A{-1, 4}, bson.A{11, 4}, bson.A{11, 6}, bson.A{-1, 6}, bson.A{-1, 4})}, true},
+		{"intersects touching edge", builder.New().Geo("loc").Intersects(square).Build(),
+			bson.M{"loc": polygon(bson.A{10, 0}, bson.A{12, 0}, bson.A{12, 10}, bson.A{10, 0})}, true},
+		{"intersects disjoint", builder.New().Geo("loc").Intersects(square).Build(),
+			bson.M{"loc": polygon(bson.A{11, 0}, bson.A{12, 0}, bson.A{12, 1}, bson.A{11, 0})}, false},
+		{"intersects multi polygon", builder.New().Geo("loc").Intersects(builder.MultiPolygon{
+			{{{20, 20}, {21, 20}, {21, 21}, {20, 20}}}, square,
+		}).Build(), bson.M{"loc": point(3, 3)}, true},
+		{"near min distance", builder.New().Geo("loc").NearSphere(builder.NewPoint(0, 0), 200000, 0).Build(),
+			bson.M{"loc": point(1, 0)}, false},
+		{"near between distances", builder.New().Geo("loc").NearSphere(builder.NewPoint(0, 0), 100000, 200000).Build(),
+			bson.M{"loc": point(1, 0)}, true},
+		{"near polygon", builder.New().Geo("loc").Near(builder.NewPoint(0, 0), 0, 1000).Build(),
+			bson.M{"loc": polygon(bson.A{0, 0}, bson.A{1, 0}, bson.A{1, 1}, bson.A{0, 0})}, false},
+
+		// arrays of shapes match if any of them matches
+		{"array of points", builder.New().Geo("loc").WithinPolygon(square).Build(),
+			bson.M{"loc": bson.A{point(20, 20), point(5, 5)}}, true},
+		{"array of points outside", builder.New().Geo("loc").WithinPolygon(square).Build(),
+			bson.M{"loc": bson.A{point(20, 20), point(30, 30)}}, false},
+		{"array of legacy pairs", builder.New().Geo("loc").Intersects(square).Build(),
+			bson.M{"loc": bson.A{bson.A{20, 20}, bson.A{1, 1}}}, true},
+		{"path through array", builder.New().Geo("places.loc").WithinPolygon(square).Build(),
+			bson.M{"places": bson.A{bson.M{"loc": point(20, 20)}, bson.M{"loc": point(1, 1)}}}, true},
+
+		// null, missing and invalid shapes never match
+		{"missing", builder.New().Geo("loc").WithinPolygon(square).Build(), bson.M{}, false},
+		{"null", builder.New().Geo("loc").Intersects(square).Build(), bson.M{"loc": nil}, false},
+		{"empty array", builder.New().Geo("loc").Intersects(square).Build(), bson.M{"loc": bson.A{}}, false},
+		{"not a shape", builder.New().Geo("loc").Intersects(square).Build(), bson.M{"loc": bson.M{"type": "Point"}}, false},
+		{"near missing", builder.New().Geo("loc").NearSphere(builder.NewPoint(0, 0), 0, 0).Build(), bson.M{}, false},
+	}
+	for _, c := range cases {
+		ok, err := builder.Match(c.filter, c.doc)
+		assert.Nil(t, err, c.name)
+		assert.Equal(t, c.match, ok, c.name)
+	}
+}
+
+func TestMatch_Expr(t *testing.T) {
+	day := time.Date(2021, 1, 31, 0, 0, 0, 0, time.UTC)
+	doc := bson.M{
+		"name":    "Volinda",
+		"age":     int32(20),
+		"zero":    int32(0),
+		"ratio":   0.5,
+		"deleted": nil,
+		"created": day,
+		"tags":    bson.A{"a", "b"},
+		"items": bson.A{
+			bson.M{"sku": "x-1", "qty": int32(1)},
+			bson.M{"sku": "x-2"},
+			"scalar",
+		},
+		"matrix": bson.A{bson.A{bson.M{"v": int32(1)}}, bson.M{"v": int32(2)}, int32(3)},
+	}
+
+	cases := []struct {
+		name  string
+		expr  builder.Expression
+		match bool
+	}{
+		{"compare fields", builder.Field("age").Gt(builder.Field("zero")), true},
+		{"compare across number types", builder.Field("age").Eq(builder.Lit(20.0)), true},
+		{"compare across types", builder.Field("name").Gt(builder.Field("age")), true},
+		{"subtract", builder.Field("age").Subtract(builder.Lit(5)).Eq(builder.Lit(15)), true},
+		{"multiply", builder.Field("age").Multiply(builder.Field("ratio")).Eq(builder.Lit(10)), true},
+		{"divide", builder.Field("age").Divide(builder.Lit(8)).Eq(builder.Lit(2.5)), true},
+		{"add date", builder.Field("created").Add(builder.Lit(24 * 60 * 60 * 1000)).Eq(builder.Lit(day.AddDate(0, 0, 1))), true},
+		{"subtract dates", builder.Lit(day.Add(time.Second)).Subtract(builder.Field("created")).Eq(builder.Lit(1000)), true},
+		{"dateDiff month end", builder.Field("created").DateDiff(builder.Lit(day.AddDate(0, 0, 1)), "month").Eq(builder.Lit(1)), true},
+		{"dateDiff week starts on sunday", builder.Lit(day.AddDate(0, 0, -1)).DateDiff(builder.Field("created"), "week").Eq(builder.Lit(1)), true},
+		{"dateDiff same week", builder.Field("created").DateDiff(builder.Lit(day.AddDate(0, 0, 6)), "week").Eq(builder.Lit(0)), true},
+		{"strLenCP unicode", builder.Lit("héllo").StrLenCP().Eq(builder.Lit(5)), true},
+		{"and", builder.Field("age").And(builder.Field("name")), true},
+		{"and zero", builder.Field("age").And(builder.Field("zero")), false},
+		{"or", builder.Field("zero").Or(builder.Field("deleted"), builder.Field("ratio")), true},
+
+		// null and missing
+		{"null eq null", builder.Field("deleted").Eq(builder.Lit(nil)), true},
+		{"missing eq null", builder.Field("nothing").Eq(builder.Lit(nil)), false},
+		{"missing eq missing", builder.Field("nothing").Eq(builder.Field("other")), true},
+		{"missing lt null", builder.Field("nothing").Lt(builder.Lit(nil)), true},
+		{"missing lt number", builder.Field("nothing").Lt(builder.Lit(0)), true},
+		{"missing gt number", builder.Field("nothing").Gt(builder.Lit(0)), false},
+		{"null lt number", builder.Field("deleted").Lt(builder.Lit(0)), true},
+		{"add missing", builder.Field("age").Add(builder.Field("nothing")).Eq(builder.Lit(nil)), true},
+		{"add null", builder.Field("deleted").Add(builder.Lit(1)).Eq(builder.Lit(nil)), true},
+		{"dateDiff missing", builder.Field("nothing").DateDiff(builder.Lit(day), "day").Eq(builder.Lit(nil)), true},
+		{"truthy null", builder.Field("deleted").Or(builder.Field("nothing")), false},
+
+		// arrays are compared as a whole, and paths through arrays collect the values of elements
+		{"array not traversed", builder.Field("tags").Eq(builder.Lit("a")), false},
+		{"array gt string", builder.Field("tags").Gt(builder.Lit("z")), true},
+		{"array eq", builder.Field("tags").Eq(builder.Lit(bson.A{"a", "b"})), true},
+		{"path through array", builder.Field("items.sku").Eq(builder.Lit(bson.A{"x-1", "x-2"})), true},
+		{"path skips missing", builder.Field("items.qty").Size().Eq(builder.Lit(1)), true},
+		{"path keeps nested arrays", builder.Field("matrix.v").Eq(builder.Lit(bson.A{bson.A{int32(1)}, int32(2)})), true},
+		{"path through scalar", builder.Field("name.first").Eq(builder.Lit(nil)), false},
+		{"empty array truthy", builder.Field("items.nothing").And(builder.Lit(true)), true},
+	}
+	for _, c := range cases {
+		ok, err := builder.Match(builder.New().Expr(c.expr).Build(), doc)
+		assert.Nil(t, err, c.name)
+		assert.Equal(t, c.match, ok, c.name)
+	}
+
+	errCases := []builder.Expression{
+		builder.Field("nothing").Size().Eq(builder.Lit(0)),
+		builder.Field("age").StrLenCP().Eq(builder.Lit(0)),
+		builder.Field("age").Divide(builder.Field("zero")).Eq(builder.Lit(0)),
+		builder.Field("name").Add(builder.Lit(1)).Eq(builder.Lit(0)),
+		builder.Field("created").DateDiff(builder.Lit(day), "fortnight").Eq(builder.Lit(0)),
+	}
+	for _, e := range errCases {
+		_, err := builder.Match(builder.New().Expr(e).Build(), doc)
+		assert.NotNil(t, err, e.Value())
+	}
+}
+
+func TestMatch_JSONSchema(t *testing.T) {
+	schema := bson.M{
+		"bsonType": "object",
+		"required": bson.A{"name", "age"},
+		"properties": bson.M{
+			"name":   bson.M{"bsonType": "string", "minLength": 2, "maxLength": 4},
+			"age":    bson.M{"bsonType": bson.A{"int", "long", "null"}, "minimum": 0, "maximum": 150},
+			"status": bson.M{"enum": bson.A{"on", "off"}},
+			"tags": bson.M{
+				"bsonType": "array", "minItems": 1, "maxItems": 2,
+				"items": bson.M{"bsonType": "string"},
+			},
+			"pair":    bson.M{"items": bson.A{bson.M{"bsonType": "string"}, bson.M{"bsonType": "int"}}},
+			"address": bson.M{"bsonType": "object", "required": bson.A{"city"}, "additionalProperties": false, "properties": bson.M{"city": bson.M{}}},
+		},
+		"additionalProperties": bson.M{"bsonType": "bool"},
+	}
+
+	cases := []struct {
+		name  string
+		doc   bson.M
+		match bool
+	}{
+		{"valid", bson.M{"name": "ab", "age": int32(1)}, true},
+		{"required null", bson.M{"name": "ab", "age": nil}, true},
+		{"required missing", bson.M{"name": "ab"}, false},
+		{"bsonType", bson.M{"name": "ab", "age": 1.5}, false},
+		{"bsonType long", bson.M{"name": "ab", "age": int64(1)}, true},
+		{"minimum", bson.M{"name": "ab", "age": int32(-1)}, false},
+		{"maximum", bson.M{"name": "ab", "age": int32(151)}, false},
+		{"minLength", bson.M{"name": "a", "age": int32(1)}, false},
+		{"maxLength counts code points", bson.M{"name": "héll", "age": int32(1)}, true},
+		{"maxLength", bson.M{"name": "hello", "age": int32(1)}, false},
+		{"enum", bson.M{"name": "ab", "age": int32(1), "status": "on"}, true},
+		{"not in enum", bson.M{"name": "ab", "age": int32(1), "status": "x"}, false},
+		{"items", bson.M{"name": "ab", "age": int32(1), "tags": bson.A{"a", "b"}}, true},
+		{"items type", bson.M{"name": "ab", "age": int32(1), "tags": bson.A{"a", 1}}, false},
+		{"minItems", bson.M{"name": "ab", "age": int32(1), "tags": bson.A{}}, false},
+		{"maxItems", bson.M{"name": "ab", "age": int32(1), "tags": bson.A{"a", "b", "c"}}, false},
+		{"array is not a scalar", bson.M{"name": bson.A{"ab"}, "age": int32(1)}, false},
+		{"tuple items", bson.M{"name": "ab", "age": int32(1), "pair": bson.A{"a", int32(1), "extra"}}, true},
+		{"tuple items type", bson.M{"name": "ab", "age": int32(1), "pair": bson.A{int32(1), "a"}}, false},
+		{"nested", bson.M{"name": "ab", "age": int32(1), "address": bson.M{"city": "hz"}}, true},
+		{"nested required", bson.M{"name": "ab", "age": int32(1), "address": bson.M{}}, false},
+		{"nested additional", bson.M{"name": "ab", "age": int32(1), "address": bson.M{"city": "hz", "zip": "1"}}, false},
+		{"additional schema", bson.M{"name": "ab", "age": int32(1), "active": true}, true},
+		{"additional schema type", bson.M{"name": "ab", "age": int32(1), "active": "yes"}, false},
+	}
+	for _, c := range cases {
+		ok, err := builder.Match(bson.M{"$jsonSchema": schema}, c.doc)
+		assert.Nil(t, err, c.name)
+		assert.Equal(t, c.match, ok, c.name)
+	}
+
+	// $jsonSchema can be combined with other query operators
+	ok, err := builder.Match(bson.M{"$jsonSchema": schema, "age": bson.M{"$gt": 10}}, bson.M{"name": "ab", "age": int32(1)})
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	_, err = builder.Match(bson.M{"$jsonSchema": bson.M{"pattern": "^a"}}, bson.M{})
+	assert.NotNil(t, err)
+	_, err = builder.Match(bson.M{"$jsonSchema": bson.M{"required": "name"}}, bson.M{})
+	assert.NotNil(t, err)
+}
+
+func TestMatch_NullMissing(t *testing.T) {
+	cases := []struct {
+		name   string
+		filter bson.M
+		doc    bson.M
+		match  bool
+	}{
+		{"eq null missing", bson.M{"a": nil}, bson.M{}, true},
+		{"eq null null", bson.M{"a": nil}, bson.M{"a": nil}, true},
+		{"eq null value", bson.M{"a": nil}, bson.M{"a": 1}, false},
+		{"eq null array element", bson.M{"a": nil}, bson.M{"a": bson.A{1, nil}}, true},
+		{"eq null empty array", bson.M{"a": nil}, bson.M{"a": bson.A{}}, false},
+		{"ne null missing", builder.New().Field("a").NotNull().Build(), bson.M{}, false},
+		{"ne null null", builder.New().Field("a").NotNull().Build(), bson.M{"a": nil}, false},
+		{"ne value missing", builder.New().Num("a").Ne(1).Build(), bson.M{}, true},
+		{"in null missing", builder.New().Any("a").In(bson.A{nil, 1}).Build(), bson.M{}, true},
+		{"nin value missing", builder.New().Num("a").Nin([]int{1}).Build(), bson.M{}, true},
+		{"nin null missing", builder.New().Any("a").Nin(bson.A{nil}).Build(), bson.M{}, false},
+		{"gte null missing", bson.M{"a": bson.M{"$gte": nil}}, bson.M{}, true},
+		{"gt null missing", bson.M{"a": bson.M{"$gt": nil}}, bson.M{}, false},
+		{"lt value missing", builder.New().Num("a").Lt(1).Build(), bson.M{}, false},
+		{"lt value null", builder.New().Num("a").Lt(1).Build(), bson.M{"a": nil}, false},
+		{"exists null", builder.New().Field("a").Exists(true).Build(), bson.M{"a": nil}, true},
+		{"exists missing", builder.New().Field("a").Exists(true).Build(), bson.M{}, false},
+		{"type null", builder.New().Field("a").IsNull().Build(), bson.M{"a": nil}, true},
+		{"type null missing", builder.New().Field("a").IsNull().Build(), bson.M{}, false},
+		{"not gt missing", builder.New().Num("a").Negate().Gt(1).Build(), bson.M{}, true},
+		{"not regex missing", builder.New().Str("a").Not("^x").Build(), bson.M{}, true},
+		{"regex null", builder.New().Str("a").Regex("null").Build(), bson.M{"a": nil}, false},
+		{"size missing", builder.New().Arr("a").Size(0).Build(), bson.M{}, false},
+		{"all missing", builder.New().Arr("a").All(1).Build(), bson.M{}, false},
+		{"all null missing", builder.New().Arr("a").All(nil).Build(), bson.M{}, true},
+		{"elemMatch missing", builder.New().Arr("a").ElemMatch(func(e *builder.Builder) {
+			e.Any("").Eq(nil)
+		}).Build(), bson.M{}, false},
+		{"elemMatch scalar", builder.New().Arr("a").ElemMatch(func(e *builder.Builder) {
+			e.Num("").Gt(0)
+		}).Build(), bson.M{"a": 1}, false},
+		{"mod null", builder.New().Num("a").Mod(2, 0).Build(), bson.M{"a": nil}, false},
+
+		// paths through arrays
+		{"path null in elements", bson.M{"a.b": nil}, bson.M{"a": bson.A{bson.M{"b": 1}, bson.M{}}}, true},
+		{"path null all present", bson.M{"a.b": nil}, bson.M{"a": bson.A{bson.M{"b": 1}, bson.M{"b": 2}}}, false},
+		{"path through scalars", bson.M{"a.b": nil}, bson.M{"a": bson.A{1, 2}}, true},
+		{"path through empty array", bson.M{"a.b": nil}, bson.M{"a": bson.A{}}, true},
+		{"path exists in elements", builder.New().Field("a.b").Exists(true).Build(), bson.M{"a": bson.A{bson.M{}, bson.M{"b": nil}}}, true},
+		{"path array index", bson.M{"a.1.b": 2}, bson.M{"a": bson.A{bson.M{"b": 1}, bson.M{"b": 2}}}, true},
+		{"path array index missing", bson.M{"a.2.b": nil}, bson.M{"a": bson.A{bson.M{"b": 1}}}, true},
+		{"nested arrays", bson.M{"a": int32(3)}, bson.M{"a": bson.A{bson.A{int32(3)}}}, false},
+		{"nested array element", bson.M{"a": bson.A{int32(3)}}, bson.M{"a": bson.A{bson.A{int32(3)}}}, true},
+		{"ne through array", builder.New().Num("a.b").Ne(1).Build(), bson.M{"a": bson.A{bson.M{"b": 1}, bson.M{"b": 2}}}, false},
+	}
+	for _, c := range cases {
+		ok, err := builder.Match(c.filter, c.doc)
+		assert.Nil(t, err, c.name)
+		assert.Equal(t, c.match, ok, c.name)
+	}
+}
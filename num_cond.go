@@ -1,5 +1,13 @@
 package builder
 
+import (
+	"fmt"
+	"math"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
 // numCond represents a numeric-type condition builder.
 // For convince, the val passed in MUST to be a numeric type,
 // APIs WILL NOT check its type.
@@ -8,37 +16,45 @@ type numCond struct {
 }
 
 func newNumCond(key string, builder *Builder) *numCond {
-	return &numCond{
+	c := &numCond{
 		cond: newCond(key, builder),
 	}
+	c.kind = NumParam
+	return c
+}
+
+// Negate makes the following operators to be wrapped into `$not`, eg: Num("age").Negate().Gt(5).
+func (c *numCond) Negate() *numCond {
+	c.cond.Negate()
+	return c
 }
 
-// Eq adds `$eq: val` to the c.m
+// Eq adds `$eq: val` to the builder
 func (c *numCond) Eq(val interface{}) *Builder {
 	return c.cond.Eq(val)
 }
 
-// Ne adds `$ne: val` to the c.m
+// Ne adds `$ne: val` to the builder
 func (c *numCond) Ne(val interface{}) *Builder {
 	return c.cond.Ne(val)
 }
 
-// Lt adds `$lt: val` to the c.m
+// Lt adds `$lt: val` to the builder
 func (c *numCond) Lt(val interface{}) *Builder {
 	return c.cond.Lt(val)
 }
 
-// Lte adds `$lte: val` to the c.m
+// Lte adds `$lte: val` to the builder
 func (c *numCond) Lte(val interface{}) *Builder {
 	return c.cond.Lte(val)
 }
 
-// Gt adds `$gt: val` to the c.m
+// Gt adds `$gt: val` to the builder
 func (c *numCond) Gt(val interface{}) *Builder {
 	return c.cond.gt(val)
 }
 
-// Gte adds `$gte: val` to the c.m
+// Gte adds `$gte: val` to the builder
 func (c *numCond) Gte(val interface{}) *Builder {
 	return c.cond.Gte(val)
 }
@@ -49,7 +65,94 @@ func (c *numCond) Between(min interface{}, max interface{}) *Builder {
 	return c.cond.Lte(max)
 }
 
-// In adds `$nin: vals` to the c.m
+// In adds `$nin: vals` to the builder
 func (c *numCond) In(nums interface{}) *Builder {
 	return c.cond.In(nums)
 }
+
+// Mod adds `$mod: [divisor, remainder]` to the builder
+//
+// divisor should be positive and remainder should be in [0, divisor), otherwise the condition fails.
+func (c *numCond) Mod(divisor, remainder int64) *Builder {
+	if divisor <= 0 {
+		c.builder.fail(c.key, _mod, fmt.Errorf("divisor %d should be positive", divisor))
+		return c.builder
+	}
+	if remainder < 0 || remainder >= divisor {
+		c.builder.fail(c.key, _mod, fmt.Errorf("remainder %d should be in [0, %d)", remainder, divisor))
+		return c.builder
+	}
+	return c.cond.set(_mod, []int64{divisor, remainder})
+}
+
+// BitsAllSet adds `$bitsAllSet: mask` to the builder
+//
+// mask can be an integer bitmask in [0, math.MaxInt32] as MongoDB requires, a slice of non-negative bit positions,
+// or a []byte which will be used as BinData.
+func (c *numCond) BitsAllSet(mask interface{}) *Builder {
+	return c.bits(_bitsAllSet, mask)
+}
+
+// BitsAnySet adds `$bitsAnySet: mask` to the builder, see BitsAllSet for the mask.
+func (c *numCond) BitsAnySet(mask interface{}) *Builder {
+	return c.bits(_bitsAnySet, mask)
+}
+
+// BitsAllClear adds `$bitsAllClear: mask` to the builder, see BitsAllSet for the mask.
+func (c *numCond) BitsAllClear(mask interface{}) *Builder {
+	return c.bits(_bitsAllClear, mask)
+}
+
+// BitsAnyClear adds `$bitsAnyClear: mask` to the builder, see BitsAllSet for the mask.
+func (c *numCond) BitsAnyClear(mask interface{}) *Builder {
+	return c.bits(_bitsAnyClear, mask)
+}
+
+// bits adds `op: mask` to the builder if the mask is valid.
+func (c *numCond) bits(op string, mask interface{}) *Builder {
+	m, err := bitmask(mask)
+	if err != nil {
+		c.builder.fail(c.key, op, err)
+		return c.builder
+	}
+	return c.cond.set(op, m)
+}
+
+// bitmask validates the mask of bitwise operators, []byte will be converted to BinData.
+func bitmask(mask interface{}) (interface{}, error) {
+	switch m := mask.(type) {
+	case []byte:
+		return primitive.Binary{Data: m}, nil
+	case primitive.Binary:
+		return m, nil
+	}
+
+	v := reflect.ValueOf(mask)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Int() < 0 || v.Int() > math.MaxInt32 {
+			return nil, fmt.Errorf("bitmask %d should be in [0, %d]", v.Int(), math.MaxInt32)
+		}
+		return mask, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v.Uint() > math.MaxInt32 {
+			return nil, fmt.Errorf("bitmask %d should be in [0, %d]", v.Uint(), math.MaxInt32)
+		}
+		return mask, nil
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			pos := v.Index(i)
+			switch pos.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				if pos.Int() < 0 {
+					return nil, fmt.Errorf("bit position %d should be non-negative", pos.Int())
+				}
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			default:
+				return nil, fmt.Errorf("bit position should be an integer, got %s", pos.Kind())
+			}
+		}
+		return mask, nil
+	}
+	return nil, fmt.Errorf("unsupported bitmask type %T", mask)
+}
@@ -0,0 +1,55 @@
+package builder_test
+
+import (
+	"math"
+	"testing"
+
+	builder "github.com/JsyTech/mongo-filter-builder"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestNumCond_Bits(t *testing.T) {
+	b := builder.New().Num("perm").BitsAllSet(0b101).Build()
+	c := bson.M{"perm": bson.M{"$bitsAllSet": 0b101}}
+	assert.Equal(t, c, b)
+
+	b = builder.New().Num("perm").BitsAnySet([]int{1, 5}).Num("perm").BitsAllClear(uint8(0)).Build()
+	c = bson.M{"perm": bson.M{"$bitsAnySet": []int{1, 5}, "$bitsAllClear": uint8(0)}}
+	assert.Equal(t, c, b)
+
+	b = builder.New().Num("perm").BitsAnyClear([]byte{0x21}).Build()
+	c = bson.M{"perm": bson.M{"$bitsAnyClear": primitive.Binary{Data: []byte{0x21}}}}
+	assert.Equal(t, c, b)
+
+	assert.Panics(t, func() { builder.New().Num("perm").BitsAllSet(-1) })
+	assert.Panics(t, func() { builder.New().Num("perm").BitsAllSet([]int{1, -2}) })
+	assert.Panics(t, func() { builder.New().Num("perm").BitsAllSet([]string{"1"}) })
+	assert.Panics(t, func() { builder.New().Num("perm").BitsAllSet(1.5) })
+
+	// integer masks are limited to 32-bit signed integers by MongoDB
+	b = builder.New().Num("perm").BitsAllSet(math.MaxInt32).Build()
+	c = bson.M{"perm": bson.M{"$bitsAllSet": math.MaxInt32}}
+	assert.Equal(t, c, b)
+	assert.Panics(t, func() { builder.New().Num("perm").BitsAllSet(math.MaxInt32 + 1) })
+	assert.Panics(t, func() { builder.New().Num("perm").BitsAllSet(uint32(math.MaxInt32 + 1)) })
+}
+
+func TestNumCond_Mod(t *testing.T) {
+	b := builder.New().Num("id").Mod(4, 1).Build()
+	c := bson.M{"id": bson.M{"$mod": []int64{4, 1}}}
+	assert.Equal(t, c, b)
+
+	b = builder.New().Str("type").Eq("job").Partition("seq", 8, 3).Build()
+	c = bson.M{
+		"type": bson.M{"$eq": "job"},
+		"seq":  bson.M{"$mod": []int64{8, 3}},
+	}
+	assert.Equal(t, c, b)
+
+	assert.Panics(t, func() { builder.New().Num("id").Mod(0, 0) })
+	assert.Panics(t, func() { builder.New().Num("id").Mod(-2, 1) })
+	assert.Panics(t, func() { builder.New().Num("id").Mod(4, 4) })
+	assert.Panics(t, func() { builder.New().Partition("id", 4, -1) })
+}
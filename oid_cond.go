@@ -8,15 +8,29 @@ type oidCond struct {
 }
 
 func newOidCond(key string, builderRef *Builder) *oidCond {
-	return &oidCond{
+	c := &oidCond{
 		cond: newCond(key, builderRef),
 	}
+	c.kind = OidParam
+	return c
+}
+
+// Negate makes the following operators to be wrapped into `$not`, eg: Oid().Negate().Eq(hex).
+func (c *oidCond) Negate() *oidCond {
+	c.cond.Negate()
+	return c
 }
 
 func (c *oidCond) Eq(oid string) *Builder {
 	id, err := primitive.ObjectIDFromHex(oid)
 	if err != nil {
-		panic(err)
+		c.builder.fail(c.key, _eq, err)
+		return c.builder
 	}
 	return c.cond.Eq(id)
 }
+
+// EqParam adds `$eq: Param(name)` to the builder, see Template.
+func (c *oidCond) EqParam(name string) *Builder {
+	return c.cond.Eq(Param(name))
+}
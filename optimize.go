@@ -0,0 +1,347 @@
+package builder
+
+import (
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Optimize simplifies the conditions of the builder without changing the matched documents:
+//
+//   - nested `$and` and `$or` are flattened, and duplicate conditions are removed.
+//   - ranges of the same field are merged, eg: `$gte: 5, $gt: 3` becomes `$gte: 5`.
+//   - duplicate values of `$in` and `$nin` are removed, and `$in` of a single value becomes `$eq`.
+//   - conditions shared by all `$or` branches are factored out of `$or`.
+//   - `$or` branches of equalities on the same field are collapsed into `$in`.
+func (b *Builder) Optimize() *Builder {
+	opt := FromAST(Rewrite(b.AST(), RewriterFunc(optimizeNode)))
+	b.branches, b.cur, b.texts = opt.branches, opt.cur, opt.texts
+	return b
+}
+
+// Simplify simplifies the filter with Builder.Optimize.
+// Note that literal values are converted to `$eq`, see FromBSON.
+func Simplify(filter bson.M) (bson.M, error) {
+	b, err := FromBSON(filter)
+	if err != nil {
+		return nil, err
+	}
+	return b.Optimize().Build(), nil
+}
+
+// optimizeNode optimizes n whose children have been optimized already.
+func optimizeNode(n Node) Node {
+	switch n := n.(type) {
+	case *And:
+		return optimizeAnd(n)
+	case *Or:
+		return optimizeOr(n)
+	case *Compare:
+		// operators inside Not are optimized here as well, so `$in` isn't converted to `$eq`
+		// which may conflict with an `$eq` of the same Not, see optimizeAnd.
+		return optimizeCompare(n, false)
+	}
+	return n
+}
+
+// optimizeAnd flattens nested And, removes duplicate children and merges ranges.
+func optimizeAnd(n *And) Node {
+	children := make([]Node, 0, len(n.Children))
+	for _, c := range n.Children {
+		if and, ok := c.(*And); ok {
+			children = append(children, and.Children...)
+			continue
+		}
+		if c, ok := c.(*Compare); ok {
+			children = append(children, optimizeCompare(c, true))
+			continue
+		}
+		children = append(children, c)
+	}
+	children = mergeRanges(dedupeNodes(children))
+	if reflect.DeepEqual(children, n.Children) {
+		return n
+	}
+	return &And{Children: children}
+}
+
+// rangeOps maps range operators to whether they are lower bounds.
+var rangeOps = map[string]bool{_gt: true, _gte: true, _lt: false, _lte: false}
+
+// mergeRanges keeps the tightest lower bound and upper bound of each field,
+// the bound is placed at the position of the first bound of the same side.
+//
+// Bounds with values of different types are kept since they can be matched by different array elements.
+func mergeRanges(nodes []Node) []Node {
+	type bound struct {
+		field string
+		lower bool
+	}
+	first := map[bound]int{}
+	res := make([]Node, 0, len(nodes))
+	for _, n := range nodes {
+		c, ok := n.(*Compare)
+		if !ok {
+			res = append(res, n)
+			continue
+		}
+		lower, isRange := rangeOps[c.Op]
+		if !isRange {
+			res = append(res, n)
+			continue
+		}
+		k := bound{c.Field, lower}
+		i, exists := first[k]
+		if !exists {
+			first[k] = len(res)
+			res = append(res, n)
+			continue
+		}
+		prev := res[i].(*Compare)
+		if !sameTypeBracket(prev.Value, c.Value) {
+			res = append(res, n)
+			continue
+		}
+		if tighter(c, prev, lower) {
+			res[i] = c
+		}
+	}
+	return res
+}
+
+// tighter reports whether the bound a is tighter than b.
+func tighter(a, b *Compare, lower bool) bool {
+	cmp := CompareValues(a.Value, b.Value)
+	if !lower {
+		cmp = -cmp
+	}
+	if cmp != 0 {
+		return cmp > 0
+	}
+	// the exclusive bound is tighter for the same value
+	return (a.Op == _gt || a.Op == _lt) && a.Op != b.Op
+}
+
+// optimizeCompare removes duplicate values of `$in` and `$nin`,
+// and converts `$in` of a single value to `$eq` if toEq is true.
+func optimizeCompare(n *Compare, toEq bool) Node {
+	if n.Op != _in && n.Op != _nin {
+		return n
+	}
+	vals, ok := sliceValues(n.Value)
+	if !ok {
+		return n
+	}
+	uniq := dedupeValues(vals)
+	if toEq && n.Op == _in && len(uniq) == 1 && !isRegex(uniq[0]) {
+		return &Compare{Field: n.Field, Op: _eq, Value: uniq[0]}
+	}
+	if len(uniq) == len(vals) {
+		return n
+	}
+	return &Compare{Field: n.Field, Op: n.Op, Value: uniq}
+}
+
+// optimizeOr flattens nested Or, removes duplicate branches,
+// factors out conditions shared by all branches and collapses equalities into `$in`.
+func optimizeOr(n *Or) Node {
+	branches := make([]Node, 0, len(n.Children))
+	for _, c := range n.Children {
+		if or, ok := c.(*Or); ok {
+			branches = append(branches, or.Children...)
+			continue
+		}
+		branches = append(branches, c)
+	}
+	branches = dedupeNodes(branches)
+	for _, br := range branches {
+		// an empty branch matches all documents
+		if len(andChildren(br)) == 0 {
+			return &And{}
+		}
+	}
+
+	if common, rest, ok := factorOr(branches); ok {
+		if rest == nil {
+			return optimizeAnd(&And{Children: common})
+		}
+		return optimizeAnd(&And{Children: append(common, optimizeOr(&Or{Children: rest}))})
+	}
+
+	branches = collapseEqualities(branches)
+	if len(branches) == 1 {
+		return branches[0]
+	}
+	if reflect.DeepEqual(branches, n.Children) {
+		return n
+	}
+	return &Or{Children: branches}
+}
+
+// andChildren returns the conditions of an Or branch.
+func andChildren(n Node) []Node {
+	if and, ok := n.(*And); ok {
+		return and.Children
+	}
+	return []Node{n}
+}
+
+// factorOr finds conditions shared by all branches, and returns them with the rest of branches.
+// If any branch only contains shared conditions, the rest is nil since the `$or` is always true then.
+func factorOr(branches []Node) (common, rest []Node, ok bool) {
+	if len(branches) < 2 {
+		return nil, nil, false
+	}
+	lists := make([][]Node, 0, len(branches))
+	for _, br := range branches {
+		lists = append(lists, andChildren(br))
+	}
+
+	for _, c := range lists[0] {
+		shared := true
+		for _, l := range lists[1:] {
+			if indexOfNode(l, c) < 0 {
+				shared = false
+				break
+			}
+		}
+		if shared && indexOfNode(common, c) < 0 {
+			common = append(common, c)
+		}
+	}
+	if len(common) == 0 {
+		return nil, nil, false
+	}
+
+	for _, l := range lists {
+		remain := []Node{}
+		for _, c := range l {
+			if indexOfNode(common, c) < 0 {
+				remain = append(remain, c)
+			}
+		}
+		if len(remain) == 0 {
+			return common, nil, true
+		}
+		rest = append(rest, &And{Children: remain})
+	}
+	return common, rest, true
+}
+
+// collapseEqualities collapses branches of equalities on the same field into `$in`,
+// the `$in` is placed at the position of the first branch of the field.
+func collapseEqualities(branches []Node) []Node {
+	fieldsOf := map[string][]int{}
+	for i, br := range branches {
+		if field, _, ok := equality(br); ok {
+			fieldsOf[field] = append(fieldsOf[field], i)
+		}
+	}
+
+	res := make([]Node, 0, len(branches))
+	for i, br := range branches {
+		field, _, ok := equality(br)
+		if !ok || len(fieldsOf[field]) < 2 {
+			res = append(res, br)
+			continue
+		}
+		indexes := fieldsOf[field]
+		if indexes[0] != i {
+			continue
+		}
+		vals := []interface{}{}
+		for _, j := range indexes {
+			_, v, _ := equality(branches[j])
+			vals = append(vals, v...)
+		}
+		res = append(res, optimizeCompare(&Compare{Field: field, Op: _in, Value: vals}, true))
+	}
+	return res
+}
+
+// equality returns the field and values if n only matches the field with some values,
+// which is `$eq`, a literal value or `$in`.
+func equality(n Node) (field string, vals []interface{}, ok bool) {
+	children := andChildren(n)
+	if len(children) != 1 {
+		return "", nil, false
+	}
+	c, isCompare := children[0].(*Compare)
+	if !isCompare {
+		return "", nil, false
+	}
+	switch c.Op {
+	case _eq, "":
+		// a regex literal is a pattern, and `$eq: /re/` only matches the same regex value
+		if isRegex(c.Value) {
+			return "", nil, false
+		}
+		return c.Field, []interface{}{c.Value}, true
+	case _in:
+		vals, ok := sliceValues(c.Value)
+		return c.Field, vals, ok
+	}
+	return "", nil, false
+}
+
+// isRegex reports whether v is a regex.
+func isRegex(v interface{}) bool {
+	_, ok := v.(primitive.Regex)
+	return ok
+}
+
+// sliceValues returns elements of a slice or an array.
+func sliceValues(v interface{}) ([]interface{}, bool) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+	if _, isBinary := v.([]byte); isBinary {
+		return nil, false
+	}
+	vals := make([]interface{}, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		vals = append(vals, rv.Index(i).Interface())
+	}
+	return vals, true
+}
+
+// dedupeValues removes values equal to a previous one in the BSON comparison order, eg: 1 and 1.0.
+func dedupeValues(vals []interface{}) []interface{} {
+	res := make([]interface{}, 0, len(vals))
+	for _, v := range vals {
+		dup := false
+		for _, u := range res {
+			if sameTypeBracket(u, v) && CompareValues(u, v) == 0 {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// dedupeNodes removes nodes deeply equal to a previous one.
+func dedupeNodes(nodes []Node) []Node {
+	res := make([]Node, 0, len(nodes))
+	for _, n := range nodes {
+		if indexOfNode(res, n) < 0 {
+			res = append(res, n)
+		}
+	}
+	return res
+}
+
+// indexOfNode returns the index of the node deeply equal to n, or -1.
+func indexOfNode(nodes []Node, n Node) int {
+	for i, c := range nodes {
+		if reflect.DeepEqual(c, n) {
+			return i
+		}
+	}
+	return -1
+}
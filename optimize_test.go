@@ -0,0 +1,95 @@
+package builder_test
+
+import (
+	"testing"
+
+	builder "github.com/JsyTech/mongo-filter-builder"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestBuilder_Optimize(t *testing.T) {
+	// ranges
+	b := builder.New().Num("age").Gte(5).Num("age").Gt(3).Num("age").Lt(10).Optimize().Build()
+	c := bson.M{"age": bson.M{"$gte": 5, "$lt": 10}}
+	assert.Equal(t, c, b)
+
+	b = builder.New().Num("age").Gte(5).Num("age").Gt(5).Optimize().Build()
+	c = bson.M{"age": bson.M{"$gt": 5}}
+	assert.Equal(t, c, b)
+
+	// $in
+	b = builder.New().Str("name").In("a", "b", "a").Num("age").In([]int{1}).Optimize().Build()
+	c = bson.M{"name": bson.M{"$in": []interface{}{"a", "b"}}, "age": bson.M{"$eq": 1}}
+	assert.Equal(t, c, b)
+
+	// $in of a single value in $not is kept, which may be used with $eq
+	not := builder.New().Str("name").Negate()
+	not.Eq("a")
+	b = not.In("b", "b").Optimize().Build()
+	c = bson.M{"name": bson.M{"$not": bson.M{"$eq": "a", "$in": []interface{}{"b"}}}}
+	assert.Equal(t, c, b)
+
+	// $or of equalities
+	b = builder.New().
+		Str("name").Eq("a").
+		Or().Str("name").Eq("b").
+		Or().Str("name").In("a", "c").
+		Optimize().Build()
+	c = bson.M{"name": bson.M{"$in": []interface{}{"a", "b", "c"}}}
+	assert.Equal(t, c, b)
+
+	// shared conditions
+	b = builder.New().
+		Str("status").Eq("active").Str("name").Eq("a").
+		Or().Str("status").Eq("active").Str("name").Eq("b").
+		Or().Str("status").Eq("active").Num("age").Gt(18).
+		Optimize().Build()
+	c = bson.M{
+		"status": bson.M{"$eq": "active"},
+		"$or": []bson.M{
+			{"name": bson.M{"$in": []interface{}{"a", "b"}}},
+			{"age": bson.M{"$gt": 18}},
+		},
+	}
+	assert.Equal(t, c, b)
+
+	// the $or is always true if a branch only has shared conditions
+	b = builder.New().
+		Str("status").Eq("active").
+		Or().Str("status").Eq("active").Str("name").Eq("b").
+		Optimize().Build()
+	c = bson.M{"status": bson.M{"$eq": "active"}}
+	assert.Equal(t, c, b)
+
+	// nested groups are flattened
+	b = builder.New().
+		Str("name").Eq("a").
+		AndGroup(func(g *builder.Builder) { g.Num("age").Gt(1).Str("name").Eq("a") }).
+		Optimize().Build()
+	c = bson.M{"name": bson.M{"$eq": "a"}, "age": bson.M{"$gt": 1}}
+	assert.Equal(t, c, b)
+}
+
+func TestSimplify(t *testing.T) {
+	m, err := builder.Simplify(bson.M{
+		"age":  bson.M{"$gt": 3, "$gte": 5},
+		"tags": bson.M{"$in": bson.A{"a", "a"}},
+		// values of different types can be matched by different elements of arrays
+		"score": bson.M{"$gt": 1, "$gte": "a"},
+		// regex in $in is a pattern
+		"name": bson.M{"$in": bson.A{primitive.Regex{Pattern: "^a"}}},
+	})
+	assert.Nil(t, err)
+	c := bson.M{
+		"age":   bson.M{"$gte": 5},
+		"tags":  bson.M{"$eq": "a"},
+		"score": bson.M{"$gt": 1, "$gte": "a"},
+		"name":  bson.M{"$in": bson.A{primitive.Regex{Pattern: "^a"}}},
+	}
+	assert.Equal(t, c, m)
+
+	_, err = builder.Simplify(bson.M{"$or": 1})
+	assert.NotNil(t, err)
+}
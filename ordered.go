@@ -0,0 +1,109 @@
+package builder
+
+import (
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// userValueOps are operators whose values are given by users,
+// their values are kept as they are when converting to bson.M.
+var userValueOps = map[string]bool{
+	_eq: true, _ne: true,
+	_gt: true, _gte: true, _lt: true, _lte: true,
+	_in: true, _nin: true, _all: true,
+	_literal: true,
+}
+
+// docGet returns the value of key in d.
+func docGet(d bson.D, key string) (interface{}, bool) {
+	for _, e := range d {
+		if e.Key == key {
+			return e.Value, true
+		}
+	}
+	return nil, false
+}
+
+// docSet sets key to val in d and returns the result.
+// The position of key is kept if it exists already, otherwise it's appended to the end.
+func docSet(d bson.D, key string, val interface{}) bson.D {
+	for i, e := range d {
+		if e.Key == key {
+			d[i].Value = val
+			return d
+		}
+	}
+	return append(d, bson.E{Key: key, Value: val})
+}
+
+// docDelete returns a copy of d without key.
+func docDelete(d bson.D, key string) bson.D {
+	res := make(bson.D, 0, len(d))
+	for _, e := range d {
+		if e.Key != key {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+// sortedDoc converts m to bson.D with keys sorted.
+func sortedDoc(m bson.M) bson.D {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	d := make(bson.D, 0, len(keys))
+	for _, k := range keys {
+		d = append(d, bson.E{Key: k, Value: m[k]})
+	}
+	return d
+}
+
+// toM converts d to bson.M recursively, values of userValueOps are kept as they are.
+func toM(d bson.D) bson.M {
+	return docToM(d, false)
+}
+
+// docToM converts d to bson.M, all nested documents are converted in expressions except `$literal`.
+func docToM(d bson.D, inExpr bool) bson.M {
+	m := make(bson.M, len(d))
+	for _, e := range d {
+		switch {
+		case e.Key == _literal || (!inExpr && userValueOps[e.Key]):
+			m[e.Key] = e.Value
+		default:
+			m[e.Key] = valueToM(e.Value, inExpr || e.Key == _expr)
+		}
+	}
+	return m
+}
+
+// valueToM converts nested documents in val to bson.M.
+func valueToM(val interface{}, inExpr bool) interface{} {
+	switch v := val.(type) {
+	case bson.D:
+		return docToM(v, inExpr)
+	case []bson.D:
+		res := make([]bson.M, 0, len(v))
+		for _, d := range v {
+			res = append(res, docToM(d, inExpr))
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, 0, len(v))
+		for _, e := range v {
+			res = append(res, valueToM(e, inExpr))
+		}
+		return res
+	case bson.A:
+		res := make(bson.A, 0, len(v))
+		for _, e := range v {
+			res = append(res, valueToM(e, inExpr))
+		}
+		return res
+	}
+	return val
+}
@@ -0,0 +1,381 @@
+package builder
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/x/bsonx/bsoncore"
+)
+
+// Plan is a compiled template, which marshals the filter directly to BSON with the given parameters.
+//
+// Parts of the filter without placeholders are marshaled once by Compile,
+// so binding a plan only encodes the parameters, it's meant for hot paths building the same filter shape repeatedly.
+// A plan is safe for concurrent use by multiple goroutines.
+type Plan struct {
+	insts  []inst
+	params []string
+}
+
+type instOp int
+
+const (
+	// opRaw appends inst.raw as it is.
+	opRaw instOp = iota
+	// opStart appends the header of inst.key and starts a document or an array of inst.typ.
+	opStart
+	// opEnd ends the last started document or array.
+	opEnd
+	// opParam appends inst.key with the value of parameter inst.slot.
+	opParam
+)
+
+// inst is an instruction of a plan.
+type inst struct {
+	op   instOp
+	raw  []byte
+	key  string
+	typ  bsontype.Type
+	slot int
+	// p is the placeholder of opParam, list indicates the parameter is a list of p.Kind.
+	p    Placeholder
+	list bool
+	// field and op are the field and the operator of opParam, used by errors.
+	field, fieldOp string
+}
+
+// maxPlanDepth is the maximum nesting depth of documents in a plan.
+const maxPlanDepth = 32
+
+// Compile compiles the builder into a plan, see Template and Plan.
+func (b *Builder) Compile() (*Plan, error) {
+	tpl, err := b.Template()
+	if err != nil {
+		return nil, err
+	}
+	return tpl.Compile()
+}
+
+// Compile compiles the template into a plan.
+//
+// Parameters of the plan are ordered by their names, see Plan.Params.
+func (tpl *Template) Compile() (*Plan, error) {
+	p := &Plan{params: sortedKeys(tpl.params)}
+	c := &planCompiler{plan: p, slots: make(map[string]int, len(p.params))}
+	for i, name := range p.params {
+		c.slots[name] = i
+	}
+
+	p.insts = append(p.insts, inst{op: opStart, typ: bsontype.EmbeddedDocument})
+	for _, e := range render(tpl.root) {
+		if err := c.elem(e.Key, e.Value, "", "", false); err != nil {
+			return nil, err
+		}
+	}
+	c.flush()
+	p.insts = append(p.insts, inst{op: opEnd})
+	if c.depth > maxPlanDepth {
+		return nil, fmt.Errorf("filterBuilder: filter is nested deeper than %d", maxPlanDepth)
+	}
+	return p, nil
+}
+
+// planCompiler compiles rendered documents into instructions.
+type planCompiler struct {
+	plan  *Plan
+	slots map[string]int
+	// raw stores static elements which haven't been flushed into an instruction.
+	raw []byte
+	// depth is the maximum nesting depth of documents.
+	depth, cur int
+}
+
+// elem compiles an element, field and op are the closest field and operator of the element.
+func (c *planCompiler) elem(key string, val interface{}, field, op string, inArray bool) error {
+	switch {
+	case inArray:
+	case !strings.HasPrefix(key, "$"):
+		field = key
+	case key != _and && key != _or && key != _nor && key != _not:
+		op = key
+	}
+
+	if !hasPlaceholder(val) {
+		data, err := bson.Marshal(bson.D{{Key: key, Value: val}})
+		if err != nil {
+			return fmt.Errorf("filterBuilder: failed to marshal %s: %v", key, err)
+		}
+		c.raw = append(c.raw, data[4:len(data)-1]...)
+		return nil
+	}
+
+	if p, ok := val.(Placeholder); ok {
+		slot, ok := c.slots[p.Name]
+		if !ok {
+			return fmt.Errorf("filterBuilder: parameter %q of %s on key %q is not registered by the template", p.Name, op, field)
+		}
+		c.flush()
+		c.plan.insts = append(c.plan.insts, inst{
+			op:      opParam,
+			key:     key,
+			slot:    slot,
+			p:       p,
+			list:    !inArray && (key == _in || key == _nin || key == _all),
+			field:   field,
+			fieldOp: op,
+		})
+		return nil
+	}
+
+	c.flush()
+	c.cur++
+	if c.cur > c.depth {
+		c.depth = c.cur
+	}
+	defer func() { c.cur-- }()
+	if d, ok := val.(bson.D); ok {
+		c.plan.insts = append(c.plan.insts, inst{op: opStart, key: key, typ: bsontype.EmbeddedDocument})
+		for _, e := range d {
+			if err := c.elem(e.Key, e.Value, field, op, false); err != nil {
+				return err
+			}
+		}
+	} else {
+		v := reflect.ValueOf(val)
+		c.plan.insts = append(c.plan.insts, inst{op: opStart, key: key, typ: bsontype.Array})
+		for i := 0; i < v.Len(); i++ {
+			if err := c.elem(strconv.Itoa(i), v.Index(i).Interface(), field, op, true); err != nil {
+				return err
+			}
+		}
+	}
+	c.flush()
+	c.plan.insts = append(c.plan.insts, inst{op: opEnd})
+	return nil
+}
+
+// flush adds pending static elements as an instruction.
+func (c *planCompiler) flush() {
+	if len(c.raw) == 0 {
+		return
+	}
+	c.plan.insts = append(c.plan.insts, inst{op: opRaw, raw: c.raw})
+	c.raw = nil
+}
+
+// hasPlaceholder reports whether val contains placeholders.
+func hasPlaceholder(val interface{}) bool {
+	switch v := val.(type) {
+	case Placeholder:
+		return true
+	case bson.D:
+		for _, e := range v {
+			if hasPlaceholder(e.Value) {
+				return true
+			}
+		}
+		return false
+	case bson.M:
+		for _, e := range v {
+			if hasPlaceholder(e) {
+				return true
+			}
+		}
+		return false
+	}
+	v := reflect.ValueOf(val)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return false
+	}
+	if k := v.Type().Elem().Kind(); k != reflect.Interface && k != reflect.Struct && k != reflect.Slice && k != reflect.Map {
+		return false
+	}
+	for i := 0; i < v.Len(); i++ {
+		if hasPlaceholder(v.Index(i).Interface()) {
+			return true
+		}
+	}
+	return false
+}
+
+// Params returns names of parameters in the order of arguments of Append.
+func (p *Plan) Params() []string {
+	return append([]string(nil), p.params...)
+}
+
+// Bind marshals the filter with params, like Template.Bind.
+func (p *Plan) Bind(params map[string]any) (bson.Raw, error) {
+	args := make([]interface{}, len(p.params))
+	for i, name := range p.params {
+		val, ok := params[name]
+		if !ok {
+			return nil, fmt.Errorf("filterBuilder: missing parameter %q", name)
+		}
+		args[i] = val
+	}
+	if len(params) != len(p.params) {
+		for name := range params {
+			if _, ok := p.slot(name); !ok {
+				return nil, fmt.Errorf("filterBuilder: unknown parameter %q", name)
+			}
+		}
+	}
+	return p.Append(nil, args...)
+}
+
+// slot returns the index of the parameter name.
+func (p *Plan) slot(name string) (int, bool) {
+	for i, n := range p.params {
+		if n == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Append marshals the filter with args and appends it to dst,
+// args are values of parameters in the order of Params.
+//
+// Reusing dst avoids allocations, eg: buf, err = plan.Append(buf[:0], 18, "a").
+func (p *Plan) Append(dst []byte, args ...interface{}) (bson.Raw, error) {
+	if len(args) != len(p.params) {
+		return nil, fmt.Errorf("filterBuilder: plan expects %d arguments, got %d", len(p.params), len(args))
+	}
+
+	var stack [maxPlanDepth + 1]int32
+	depth := 0
+	var err error
+	for i := range p.insts {
+		in := &p.insts[i]
+		switch in.op {
+		case opRaw:
+			dst = append(dst, in.raw...)
+		case opStart:
+			if depth != 0 {
+				dst = bsoncore.AppendHeader(dst, in.typ, in.key)
+			}
+			stack[depth], dst = bsoncore.AppendDocumentStart(dst)
+			depth++
+		case opEnd:
+			depth--
+			if dst, err = bsoncore.AppendDocumentEnd(dst, stack[depth]); err != nil {
+				return nil, err
+			}
+		case opParam:
+			if in.list {
+				dst, err = appendList(dst, in.key, in.p, args[in.slot])
+			} else {
+				dst, err = appendScalar(dst, in.key, in.p, args[in.slot])
+			}
+			if err != nil {
+				return nil, &CondError{Key: in.field, Op: in.fieldOp, Cause: err}
+			}
+		}
+	}
+	return dst, nil
+}
+
+// appendList appends the list val of parameter p as an array.
+func appendList(dst []byte, key string, p Placeholder, val interface{}) ([]byte, error) {
+	var idx int32
+	var err error
+	switch vals := val.(type) {
+	case []string:
+		if p.Kind != AnyParam && p.Kind != StrParam && p.Kind != OidParam {
+			break
+		}
+		dst = bsoncore.AppendHeader(dst, bsontype.Array, key)
+		idx, dst = bsoncore.AppendArrayStart(dst)
+		for i, s := range vals {
+			if dst, err = appendScalar(dst, strconv.Itoa(i), p, s); err != nil {
+				return nil, err
+			}
+		}
+		return bsoncore.AppendArrayEnd(dst, idx)
+	case []int:
+		if p.Kind != AnyParam && p.Kind != NumParam {
+			break
+		}
+		dst = bsoncore.AppendHeader(dst, bsontype.Array, key)
+		idx, dst = bsoncore.AppendArrayStart(dst)
+		for i, n := range vals {
+			dst = appendInt(dst, strconv.Itoa(i), n)
+		}
+		return bsoncore.AppendArrayEnd(dst, idx)
+	}
+
+	v := reflect.ValueOf(val)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("parameter %q expects a list of %s, got %T", p.Name, p.Kind, val)
+	}
+	dst = bsoncore.AppendHeader(dst, bsontype.Array, key)
+	idx, dst = bsoncore.AppendArrayStart(dst)
+	for i := 0; i < v.Len(); i++ {
+		if dst, err = appendScalar(dst, strconv.Itoa(i), p, v.Index(i).Interface()); err != nil {
+			return nil, err
+		}
+	}
+	return bsoncore.AppendArrayEnd(dst, idx)
+}
+
+// appendScalar checks val against the kind of p and appends it, see bindScalar.
+func appendScalar(dst []byte, key string, p Placeholder, val interface{}) ([]byte, error) {
+	if s, ok := val.(string); ok && p.Kind == OidParam {
+		id, err := primitive.ObjectIDFromHex(s)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %q: %v", p.Name, err)
+		}
+		return bsoncore.AppendObjectIDElement(dst, key, id), nil
+	}
+	if _, err := bindScalar(p, val); err != nil {
+		return nil, err
+	}
+
+	switch v := val.(type) {
+	case nil:
+		return bsoncore.AppendNullElement(dst, key), nil
+	case int:
+		return appendInt(dst, key, v), nil
+	case int8:
+		return bsoncore.AppendInt32Element(dst, key, int32(v)), nil
+	case int16:
+		return bsoncore.AppendInt32Element(dst, key, int32(v)), nil
+	case int32:
+		return bsoncore.AppendInt32Element(dst, key, v), nil
+	case int64:
+		return bsoncore.AppendInt64Element(dst, key, v), nil
+	case float64:
+		return bsoncore.AppendDoubleElement(dst, key, v), nil
+	case string:
+		return bsoncore.AppendStringElement(dst, key, v), nil
+	case bool:
+		return bsoncore.AppendBooleanElement(dst, key, v), nil
+	case time.Time:
+		return bsoncore.AppendDateTimeElement(dst, key, int64(primitive.NewDateTimeFromTime(v))), nil
+	case primitive.DateTime:
+		return bsoncore.AppendDateTimeElement(dst, key, int64(v)), nil
+	case primitive.ObjectID:
+		return bsoncore.AppendObjectIDElement(dst, key, v), nil
+	}
+
+	t, data, err := bson.MarshalValue(val)
+	if err != nil {
+		return nil, fmt.Errorf("parameter %q: %v", p.Name, err)
+	}
+	return append(bsoncore.AppendHeader(dst, t, key), data...), nil
+}
+
+// appendInt appends n as int32 if it fits, otherwise as int64, the same as the driver.
+func appendInt(dst []byte, key string, n int) []byte {
+	if n >= math.MinInt32 && n <= math.MaxInt32 {
+		return bsoncore.AppendInt32Element(dst, key, int32(n))
+	}
+	return bsoncore.AppendInt64Element(dst, key, int64(n))
+}
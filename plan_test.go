@@ -0,0 +1,176 @@
+package builder_test
+
+import (
+	"testing"
+	"time"
+
+	builder "github.com/JsyTech/mongo-filter-builder"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func planBuilder() *builder.Builder {
+	return builder.New().
+		Str("status").Eq("active").
+		Num("age").Gte(builder.Param("minAge")).
+		Str("name").InParam("names").
+		Date("created").Negate().LtParam("since").
+		Or().
+		Arr("tags").ContainsAll("x", builder.Param("tag")).
+		Oid().EqParam("id").
+		NorGroup(func(g *builder.Builder) {
+			g.Num("score").Between(builder.Param("minAge"), 100)
+		})
+}
+
+func TestPlan(t *testing.T) {
+	tpl, err := planBuilder().Template()
+	assert.Nil(t, err)
+	plan, err := tpl.Compile()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"id", "minAge", "names", "since", "tag"}, plan.Params())
+
+	id := primitive.NewObjectID()
+	day := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := []map[string]any{
+		{"id": id.Hex(), "minAge": 18, "names": []string{"a", "b"}, "since": day, "tag": "y"},
+		{"id": id, "minAge": int64(1) << 40, "names": []interface{}{}, "since": primitive.NewDateTimeFromTime(day), "tag": bson.M{"k": 1}},
+		{"id": id, "minAge": 2.5, "names": [1]string{"a"}, "since": day, "tag": nil},
+	}
+	for _, params := range cases {
+		raw, err := plan.Bind(params)
+		assert.Nil(t, err)
+		d, err := tpl.BindD(params)
+		assert.Nil(t, err)
+		expected, err := bson.Marshal(d)
+		assert.Nil(t, err)
+		assert.Equal(t, bson.Raw(expected), raw)
+	}
+
+	// plans without parameters are the same as Build
+	plan, err = builder.New().Str("name").Eq("a").Or().Num("age").Gt(1).Compile()
+	assert.Nil(t, err)
+	raw, err := plan.Append(nil)
+	assert.Nil(t, err)
+	expected, err := bson.Marshal(builder.New().Str("name").Eq("a").Or().Num("age").Gt(1).BuildD())
+	assert.Nil(t, err)
+	assert.Equal(t, bson.Raw(expected), raw)
+}
+
+func TestPlan_Error(t *testing.T) {
+	plan, err := planBuilder().Compile()
+	assert.Nil(t, err)
+
+	_, err = plan.Append(nil, 1)
+	assert.NotNil(t, err)
+
+	_, err = plan.Bind(map[string]any{"minAge": 1})
+	assert.NotNil(t, err)
+
+	_, err = plan.Append(nil, "x", 18, []string{"a"}, time.Now(), "y")
+	assert.Equal(t, "filterBuilder: failed to build $eq on key \"_id\": parameter \"id\": encoding/hex: invalid byte: U+0078 'x'", err.Error())
+
+	_, err = plan.Append(nil, primitive.NewObjectID(), 18, []int{1}, time.Now(), "y")
+	assert.Equal(t, "filterBuilder: failed to build $in on key \"name\": parameter \"names\" expects string, got int", err.Error())
+
+	_, err = plan.Append(nil, primitive.NewObjectID(), "18", []string{"a"}, time.Now(), "y")
+	assert.Equal(t, "filterBuilder: failed to build $gte on key \"age\": parameter \"minAge\" expects number, got string", err.Error())
+
+}
+
+func TestPlan_NestedParam(t *testing.T) {
+	b := builder.New().
+		Arr("items").Negate().ElemMatch(func(e *builder.Builder) { e.Num("qty").Gt(builder.Param("q")) }).
+		Num("age").Gte(builder.Param("minAge"))
+	plan, err := b.Compile()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"minAge", "q"}, plan.Params())
+
+	raw, err := plan.Append(nil, 18, 5)
+	assert.Nil(t, err)
+	expected, err := bson.Marshal(builder.New().
+		Arr("items").Negate().ElemMatch(func(e *builder.Builder) { e.Num("qty").Gt(5) }).
+		Num("age").Gte(18).
+		BuildD())
+	assert.Nil(t, err)
+	assert.Equal(t, bson.Raw(expected), raw)
+}
+
+var benchParams = map[string]any{
+	"id":     primitive.NewObjectID(),
+	"minAge": 18,
+	"names":  []string{"a", "b"},
+	"since":  time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+	"tag":    "y",
+}
+
+func BenchmarkBuild(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		filter := builder.New().
+			Str("status").Eq("active").
+			Num("age").Gte(benchParams["minAge"]).
+			Str("name").In(benchParams["names"].([]string)...).
+			Date("created").Negate().Lt(benchParams["since"].(time.Time)).
+			Or().
+			Arr("tags").ContainsAll("x", benchParams["tag"]).
+			Any("_id").Eq(benchParams["id"]).
+			NorGroup(func(g *builder.Builder) {
+				g.Num("score").Between(benchParams["minAge"], 100)
+			}).Build()
+		if _, err := bson.Marshal(filter); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTemplate_Bind(b *testing.B) {
+	tpl, err := planBuilder().Template()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		filter, err := tpl.Bind(benchParams)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := bson.Marshal(filter); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPlan_Append(b *testing.B) {
+	plan, err := planBuilder().Compile()
+	if err != nil {
+		b.Fatal(err)
+	}
+	args := make([]interface{}, 0, len(benchParams))
+	for _, name := range plan.Params() {
+		args = append(args, benchParams[name])
+	}
+	var buf []byte
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if buf, err = plan.Append(buf[:0], args...); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkBuild_Plain(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		builder.New().
+			Str("status").Eq("active").
+			Num("age").Gte(18).
+			Str("name").In("a", "b").
+			Or().
+			Arr("tags").ContainsAll("x", "y").
+			Build()
+	}
+}
@@ -0,0 +1,180 @@
+package builder
+
+import (
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// render renders n as a filter document.
+//
+// Values given by users are copied by render, so the result never shares values with the nodes.
+func render(n Node) bson.D {
+	switch n := n.(type) {
+	case nil:
+		return bson.D{}
+	case *And:
+		return renderAnd(n.Children)
+	case *Or:
+		return bson.D{{Key: _or, Value: renderList(n.Children)}}
+	case *Nor:
+		return bson.D{{Key: _nor, Value: renderList(n.Children)}}
+	}
+	return renderAnd([]Node{n})
+}
+
+// renderList renders nodes as documents of a logical operator.
+func renderList(nodes []Node) []bson.D {
+	res := make([]bson.D, 0, len(nodes))
+	for _, n := range nodes {
+		res = append(res, render(n))
+	}
+	return res
+}
+
+// renderAnd renders nodes into the same document.
+//
+// Fields and operators are placed in the order of their first appearance.
+// A node which can't be placed since its key is used already, such as a second `$or`
+// or the same operator of a field, is appended to `$and` instead.
+func renderAnd(nodes []Node) bson.D {
+	d := bson.D{}
+	var and []bson.D
+	spill := func(n Node) {
+		if and == nil {
+			d = append(d, bson.E{Key: _and})
+		}
+		and = append(and, render(n))
+	}
+
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case *And:
+			spill(n)
+		case *Or, *Nor, *Expr, *Text, *Raw:
+			key, val := renderTopLevel(n)
+			if _, ok := docGet(d, key); ok {
+				spill(n)
+				continue
+			}
+			d = append(d, bson.E{Key: key, Value: val})
+		default:
+			field, op, ok := fieldOp(n)
+			if !ok {
+				continue
+			}
+			v, exists := docGet(d, field)
+			if op == "" {
+				if exists {
+					spill(n)
+					continue
+				}
+				d = append(d, bson.E{Key: field, Value: userValue(n.(*Compare).Value)})
+				continue
+			}
+			var ops bson.D
+			if exists {
+				var isOps bool
+				if ops, isOps = v.(bson.D); !isOps {
+					spill(n)
+					continue
+				}
+				if _, ok := docGet(ops, op); ok {
+					spill(n)
+					continue
+				}
+			}
+			d = docSet(d, field, append(ops, bson.E{Key: op, Value: renderOpValue(n)}))
+		}
+	}
+
+	if and != nil {
+		d = docSet(d, _and, and)
+	}
+	return d
+}
+
+// renderTopLevel renders the key and value of a top level node.
+func renderTopLevel(n Node) (string, interface{}) {
+	switch n := n.(type) {
+	case *Or:
+		return _or, renderList(n.Children)
+	case *Nor:
+		return _nor, renderList(n.Children)
+	case *Expr:
+		return _expr, userValue(n.Value)
+	case *Text:
+		return _text, renderText(n)
+	case *Raw:
+		return n.Key, userValue(n.Value)
+	}
+	return "", nil
+}
+
+// renderOpValue renders the value of a field-level node.
+func renderOpValue(n Node) interface{} {
+	switch n := n.(type) {
+	case *Compare:
+		return userValue(n.Value)
+	case *Not:
+		if len(n.Ops) == 1 && n.Ops[0].Op == _regex {
+			if re, ok := n.Ops[0].Value.(primitive.Regex); ok {
+				return re
+			}
+		}
+		ops := bson.D{}
+		for _, op := range n.Ops {
+			ops = docSet(ops, op.Op, userValue(op.Value))
+		}
+		return ops
+	case *ElemMatch:
+		d := render(n.Filter)
+		if len(d) == 1 && d[0].Key == "" {
+			if ops, ok := d[0].Value.(bson.D); ok {
+				return ops
+			}
+		}
+		return d
+	}
+	return nil
+}
+
+// renderText renders the value of `$text`.
+func renderText(t *Text) bson.D {
+	d := bson.D{{Key: _search, Value: t.Search}}
+	if t.Language != "" {
+		d = append(d, bson.E{Key: _language, Value: t.Language})
+	}
+	if t.CaseSensitive != nil {
+		d = append(d, bson.E{Key: _caseSensitive, Value: *t.CaseSensitive})
+	}
+	if t.DiacriticSensitive != nil {
+		d = append(d, bson.E{Key: _diacriticSensitive, Value: *t.DiacriticSensitive})
+	}
+	return d
+}
+
+// userValue returns a copy of the value given by users if it's a slice, a map or an array,
+// other values are immutable or shared like pointers, see copyValue.
+func userValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil, string, int, int32, int64, float64, bool, primitive.Regex, primitive.ObjectID, primitive.DateTime:
+		return v
+	case []string:
+		return append([]string(nil), v...)
+	case []int:
+		return append([]int(nil), v...)
+	case []interface{}:
+		res := make([]interface{}, 0, len(v))
+		for _, e := range v {
+			res = append(res, userValue(e))
+		}
+		return res
+	}
+	switch reflect.TypeOf(v).Kind() {
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return copyValue(v)
+	}
+	return v
+}
@@ -0,0 +1,325 @@
+package builder
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SchemaOptions controls how the `$jsonSchema` is generated from a struct.
+type SchemaOptions struct {
+	// Tag is the struct tag to read schema rules from, "schema" is used as default.
+	//
+	// Rules are separated by comma, eg: `schema:"required,min=0,max=150,enum=1|2|3"`.
+	//   - required: the field is required.
+	//   - min, max: minimum and maximum of numbers, lengths of strings or items of arrays.
+	//   - enum: allowed values separated by "|".
+	Tag string
+	// Strict disallows properties which are not declared in the struct.
+	// Note that `_id` should be declared in the struct if Strict is true.
+	Strict bool
+
+	// visiting stores struct types being generated, to stop at recursive types.
+	visiting map[reflect.Type]bool
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	oidType        = reflect.TypeOf(primitive.ObjectID{})
+	dateTimeType   = reflect.TypeOf(primitive.DateTime(0))
+	decimalType    = reflect.TypeOf(primitive.Decimal128{})
+	binaryType     = reflect.TypeOf(primitive.Binary{})
+	regexType      = reflect.TypeOf(primitive.Regex{})
+	byteSliceType  = reflect.TypeOf([]byte(nil))
+	emptyIfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+)
+
+// JSONSchemaFromStruct generates a `$jsonSchema` filter from v, which should be a struct or a pointer to struct.
+//
+// Keys of properties are resolved in the same way as Builder.Auto,
+// and bsonType of each property is decided by the kind of the field:
+//
+//   - bool: "bool"
+//   - string: "string"
+//   - int8, int16, int32, uint8, uint16: "int"
+//   - int: ["int", "long"]
+//   - int64, uint, uint32, uint64: "long"
+//   - float32, float64: "double"
+//   - time.Time, primitive.DateTime: "date"
+//   - primitive.ObjectID: "objectId"
+//   - []byte: "binData"
+//   - slice, array: "array"
+//   - struct, map: "object"
+//   - pointer: the bsonType of the element type or "null"
+//
+// *Anything else than a struct or invalid schema rules will lead to a panic.
+func JSONSchemaFromStruct(v any, opts *SchemaOptions) bson.M {
+	schema, err := jsonSchemaOf(v, opts)
+	if err != nil {
+		panic(err)
+	}
+	return toM(bson.D{{Key: _jsonSchema, Value: schema}})
+}
+
+// MatchesSchema adds `$jsonSchema` generated from v to the current condition, see JSONSchemaFromStruct.
+// If `$jsonSchema` has been set already, the schema will be appended to `$and`.
+func (b *Builder) MatchesSchema(v any, opts *SchemaOptions) *Builder {
+	schema, err := jsonSchemaOf(v, opts)
+	if err != nil {
+		b.fail("", _jsonSchema, err)
+		return b
+	}
+	return b.add("", _jsonSchema, &Raw{Key: _jsonSchema, Value: schema})
+}
+
+// jsonSchemaOf generates the schema document of v.
+func jsonSchemaOf(v any, opts *SchemaOptions) (bson.D, error) {
+	o := SchemaOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.Tag == "" {
+		o.Tag = "schema"
+	}
+	o.visiting = map[reflect.Type]bool{}
+
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, errNotStruct
+	}
+	return o.objectSchema(t)
+}
+
+// objectSchema generates the schema of a struct type.
+//
+// A recursive reference to a struct being generated is described as {bsonType: "object"} only.
+func (o SchemaOptions) objectSchema(t reflect.Type) (bson.D, error) {
+	if o.visiting[t] {
+		return bsonTypeDoc("object"), nil
+	}
+	o.visiting[t] = true
+	defer delete(o.visiting, t)
+
+	props := bson.D{}
+	required := []string{}
+	if err := o.collectProps(t, &props, &required); err != nil {
+		return nil, err
+	}
+
+	schema := bson.D{{Key: "bsonType", Value: "object"}}
+	if len(required) != 0 {
+		schema = append(schema, bson.E{Key: "required", Value: required})
+	}
+	schema = append(schema, bson.E{Key: "properties", Value: props})
+	if o.Strict {
+		schema = append(schema, bson.E{Key: "additionalProperties", Value: false})
+	}
+	return schema, nil
+}
+
+// collectProps collects properties of fields of t, inline structs are flattened.
+func (o SchemaOptions) collectProps(t reflect.Type, props *bson.D, required *[]string) error {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		key, ok := fieldKey(f)
+		if !ok {
+			continue
+		}
+		if isInline(f) {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if o.visiting[ft] {
+					return fmt.Errorf("recursive inline struct %s on field %s", ft, f.Name)
+				}
+				o.visiting[ft] = true
+				err := o.collectProps(ft, props, required)
+				delete(o.visiting, ft)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		prop, err := o.typeSchema(f.Type)
+		if err != nil {
+			return err
+		}
+		prop, isRequired, err := o.applyRules(f, prop)
+		if err != nil {
+			return err
+		}
+		*props = docSet(*props, key, prop)
+		if isRequired {
+			*required = append(*required, key)
+		}
+	}
+	return nil
+}
+
+// isInline reports whether the field has `bson:",inline"`.
+func isInline(f reflect.StructField) bool {
+	bsonTag := f.Tag.Get("bson")
+	_, flags, _ := strings.Cut(bsonTag, ",")
+	for _, flag := range strings.Split(flags, ",") {
+		if flag == "inline" {
+			return true
+		}
+	}
+	return false
+}
+
+// typeSchema generates the schema of a field type.
+func (o SchemaOptions) typeSchema(t reflect.Type) (bson.D, error) {
+	switch t {
+	case timeType, dateTimeType:
+		return bsonTypeDoc("date"), nil
+	case oidType:
+		return bsonTypeDoc("objectId"), nil
+	case decimalType:
+		return bsonTypeDoc("decimal"), nil
+	case binaryType, byteSliceType:
+		return bsonTypeDoc("binData"), nil
+	case regexType:
+		return bsonTypeDoc("regex"), nil
+	case emptyIfaceType:
+		return bson.D{}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema, err := o.typeSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		if bt, ok := docGet(schema, "bsonType"); ok {
+			if bt, ok := bt.(string); ok {
+				schema = docSet(schema, "bsonType", []string{bt, "null"})
+			}
+		}
+		return schema, nil
+	case reflect.Bool:
+		return bsonTypeDoc("bool"), nil
+	case reflect.String:
+		return bsonTypeDoc("string"), nil
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return bsonTypeDoc("int"), nil
+	case reflect.Int:
+		return bsonTypeDoc([]string{"int", "long"}), nil
+	case reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return bsonTypeDoc("long"), nil
+	case reflect.Float32, reflect.Float64:
+		return bsonTypeDoc("double"), nil
+	case reflect.Slice, reflect.Array:
+		schema := bsonTypeDoc("array")
+		items, err := o.typeSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		if len(items) != 0 {
+			schema = append(schema, bson.E{Key: "items", Value: items})
+		}
+		return schema, nil
+	case reflect.Map:
+		return bsonTypeDoc("object"), nil
+	case reflect.Struct:
+		return o.objectSchema(t)
+	}
+	return bson.D{}, nil
+}
+
+// bsonTypeDoc returns {bsonType: bsonType}.
+func bsonTypeDoc(bsonType interface{}) bson.D {
+	return bson.D{{Key: "bsonType", Value: bsonType}}
+}
+
+// applyRules applies the rules in the schema tag of f to prop, and reports whether f is required.
+func (o SchemaOptions) applyRules(f reflect.StructField, prop bson.D) (res bson.D, required bool, err error) {
+	tag, ok := f.Tag.Lookup(o.Tag)
+	if !ok {
+		return prop, false, nil
+	}
+
+	t := f.Type
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	minKey, maxKey := "minimum", "maximum"
+	switch t.Kind() {
+	case reflect.String:
+		minKey, maxKey = "minLength", "maxLength"
+	case reflect.Slice, reflect.Array:
+		minKey, maxKey = "minItems", "maxItems"
+	}
+
+	for _, rule := range strings.Split(tag, ",") {
+		name, val, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		switch name {
+		case "":
+		case "required":
+			required = true
+		case "min", "max":
+			key, isLength := minKey, minKey != "minimum"
+			if name == "max" {
+				key, isLength = maxKey, maxKey != "maximum"
+			}
+			v, err := parseRuleValue(f, t, val, isLength)
+			if err != nil {
+				return nil, false, err
+			}
+			prop = docSet(prop, key, v)
+		case "enum":
+			enum := []interface{}{}
+			for _, e := range strings.Split(val, "|") {
+				v, err := parseRuleValue(f, t, e, false)
+				if err != nil {
+					return nil, false, err
+				}
+				enum = append(enum, v)
+			}
+			prop = docSet(prop, "enum", enum)
+		default:
+			return nil, false, fmt.Errorf("unknown schema rule %q on field %s", name, f.Name)
+		}
+	}
+	return prop, required, nil
+}
+
+// parseRuleValue parses the value of a rule according to the field type.
+// Lengths are always parsed as integers.
+func parseRuleValue(f reflect.StructField, t reflect.Type, val string, isLength bool) (interface{}, error) {
+	var (
+		res interface{}
+		err error
+	)
+	switch {
+	case isLength:
+		res, err = strconv.ParseInt(val, 10, 64)
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		res, err = strconv.ParseInt(val, 10, 64)
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		res, err = strconv.ParseFloat(val, 64)
+	case t.Kind() == reflect.Bool:
+		res, err = strconv.ParseBool(val)
+	default:
+		res = val
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse schema rule value %q on field %s, err: %v", val, f.Name, err)
+	}
+	return res, nil
+}
@@ -0,0 +1,120 @@
+package builder_test
+
+import (
+	"testing"
+	"time"
+
+	builder "github.com/JsyTech/mongo-filter-builder"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestJSONSchemaFromStruct(t *testing.T) {
+	type Base struct {
+		ID        primitive.ObjectID `bson:"_id" schema:"required"`
+		CreatedAt time.Time          `schema:"required"`
+	}
+	type Item struct {
+		Sku string `bson:"sku" schema:"required,min=1,max=32"`
+		Qty int32  `bson:"qty" schema:"min=1"`
+	}
+	type Order struct {
+		Base     `bson:",inline"`
+		Status   string  `bson:"status" schema:"required,enum=new|paid|done"`
+		Total    float64 `schema:"min=0"`
+		Count    int     `bson:"count"`
+		Items    []Item  `bson:"items" schema:"max=10"`
+		Note     *string `bson:"note"`
+		Ignored  string  `bson:"-"`
+		internal string
+		Tags     []string `bson:"tags"`
+	}
+
+	f := builder.JSONSchemaFromStruct(&Order{}, nil)
+	c := bson.M{"$jsonSchema": bson.M{
+		"bsonType": "object",
+		"required": []string{"_id", "created_at", "status"},
+		"properties": bson.M{
+			"_id":        bson.M{"bsonType": "objectId"},
+			"created_at": bson.M{"bsonType": "date"},
+			"status":     bson.M{"bsonType": "string", "enum": []interface{}{"new", "paid", "done"}},
+			"total":      bson.M{"bsonType": "double", "minimum": float64(0)},
+			"count":      bson.M{"bsonType": []string{"int", "long"}},
+			"items": bson.M{
+				"bsonType": "array",
+				"maxItems": int64(10),
+				"items": bson.M{
+					"bsonType": "object",
+					"required": []string{"sku"},
+					"properties": bson.M{
+						"sku": bson.M{"bsonType": "string", "minLength": int64(1), "maxLength": int64(32)},
+						"qty": bson.M{"bsonType": "int", "minimum": int64(1)},
+					},
+				},
+			},
+			"note": bson.M{"bsonType": []string{"string", "null"}},
+			"tags": bson.M{"bsonType": "array", "items": bson.M{"bsonType": "string"}},
+		},
+	}}
+	assert.Equal(t, c, f)
+
+	type Strict struct {
+		Name string `bson:"name"`
+	}
+	b := builder.New().Str("name").Eq("a").MatchesSchema(Strict{}, &builder.SchemaOptions{Strict: true}).Build()
+	c = bson.M{
+		"name": bson.M{"$eq": "a"},
+		"$jsonSchema": bson.M{
+			"bsonType":             "object",
+			"properties":           bson.M{"name": bson.M{"bsonType": "string"}},
+			"additionalProperties": false,
+		},
+	}
+	assert.Equal(t, c, b)
+
+	type BadRule struct {
+		Age int `schema:"min=abc"`
+	}
+	assert.Panics(t, func() { builder.JSONSchemaFromStruct(BadRule{}, nil) })
+	assert.Panics(t, func() { builder.JSONSchemaFromStruct(1, nil) })
+}
+
+func TestJSONSchemaFromStruct_Recursive(t *testing.T) {
+	type Tree struct {
+		Name     string  `bson:"name"`
+		Children []Tree  `bson:"children"`
+		Parent   *Tree   `bson:"parent"`
+		Sibling  *[]Tree `bson:"-"`
+	}
+	f := builder.JSONSchemaFromStruct(Tree{}, nil)
+	c := bson.M{"$jsonSchema": bson.M{
+		"bsonType": "object",
+		"properties": bson.M{
+			"name":     bson.M{"bsonType": "string"},
+			"children": bson.M{"bsonType": "array", "items": bson.M{"bsonType": "object"}},
+			"parent":   bson.M{"bsonType": []string{"object", "null"}},
+		},
+	}}
+	assert.Equal(t, c, f)
+
+	// a struct used twice but not recursively is generated in full
+	type Pair struct {
+		A struct{ X int } `bson:"a"`
+		B struct{ X int } `bson:"b"`
+	}
+	f = builder.JSONSchemaFromStruct(Pair{}, nil)
+	x := bson.M{"bsonType": "object", "properties": bson.M{"x": bson.M{"bsonType": []string{"int", "long"}}}}
+	assert.Equal(t, bson.M{"$jsonSchema": bson.M{
+		"bsonType":   "object",
+		"properties": bson.M{"a": x, "b": x},
+	}}, f)
+
+	type Loop struct {
+		*Loop `bson:",inline"`
+		Name  string
+	}
+	assert.Panics(t, func() { builder.JSONSchemaFromStruct(Loop{}, nil) })
+	_, err := builder.NewSafe().MatchesSchema(Loop{}, nil).BuildE()
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,469 @@
+package builder
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FromShell reconstructs a Builder from a filter in mongosh syntax, such as the output of ToShell.
+//
+// Besides JSON, unquoted or single-quoted keys, regex literals, trailing commas and
+// ISODate, new Date, ObjectId, NumberInt, NumberLong, NumberDecimal, BinData, Timestamp,
+// MinKey, MaxKey are supported. See FromBSON for how the filter is reconstructed.
+func FromShell(s string) (*Builder, error) {
+	p := &shellParser{src: s}
+	p.skipSpace()
+	v, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos != len(p.src) {
+		return nil, p.errorf("unexpected %q", p.src[p.pos:])
+	}
+	d, ok := v.(bson.D)
+	if !ok {
+		return nil, fmt.Errorf("filterBuilder: filter should be a document, got %T", v)
+	}
+	return FromBSOND(d)
+}
+
+// shellParser parses values in mongosh syntax.
+type shellParser struct {
+	src string
+	pos int
+}
+
+func (p *shellParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("filterBuilder: failed to parse shell filter at %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *shellParser) skipSpace() {
+	for p.pos < len(p.src) {
+		r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+		if !unicode.IsSpace(r) {
+			return
+		}
+		p.pos += size
+	}
+}
+
+func (p *shellParser) peek() byte {
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+// consume skips spaces and consumes c if it's the next byte.
+func (p *shellParser) consume(c byte) bool {
+	p.skipSpace()
+	if p.peek() == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *shellParser) expect(c byte) error {
+	if !p.consume(c) {
+		return p.errorf("expected %q", c)
+	}
+	return nil
+}
+
+func (p *shellParser) parseValue() (interface{}, error) {
+	p.skipSpace()
+	switch c := p.peek(); {
+	case c == '{':
+		return p.parseDoc()
+	case c == '[':
+		return p.parseArray()
+	case c == '"' || c == '\'':
+		return p.parseString()
+	case c == '/':
+		return p.parseRegex()
+	case c == '-' || c == '+' || c == '.' || (c >= '0' && c <= '9'):
+		return p.parseNumber()
+	case isIdentStart(c):
+		return p.parseIdentValue()
+	case c == 0:
+		return nil, p.errorf("unexpected end")
+	default:
+		return nil, p.errorf("unexpected %q", c)
+	}
+}
+
+func (p *shellParser) parseDoc() (bson.D, error) {
+	if err := p.expect('{'); err != nil {
+		return nil, err
+	}
+	d := bson.D{}
+	for !p.consume('}') {
+		p.skipSpace()
+		var key string
+		if c := p.peek(); c == '"' || c == '\'' {
+			s, err := p.parseString()
+			if err != nil {
+				return nil, err
+			}
+			key = s
+		} else {
+			key = p.parseIdent()
+			if key == "" {
+				return nil, p.errorf("expected key")
+			}
+		}
+		if err := p.expect(':'); err != nil {
+			return nil, err
+		}
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		d = append(d, bson.E{Key: key, Value: v})
+		if !p.consume(',') {
+			if err := p.expect('}'); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	return d, nil
+}
+
+func (p *shellParser) parseArray() (bson.A, error) {
+	if err := p.expect('['); err != nil {
+		return nil, err
+	}
+	a := bson.A{}
+	for !p.consume(']') {
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, v)
+		if !p.consume(',') {
+			if err := p.expect(']'); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	return a, nil
+}
+
+func (p *shellParser) parseString() (string, error) {
+	quote := p.src[p.pos]
+	p.pos++
+	var sb strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return sb.String(), nil
+		case c == '\\':
+			if p.pos+1 >= len(p.src) {
+				return "", p.errorf("unterminated string")
+			}
+			p.pos++
+			switch e := p.src[p.pos]; e {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case 'r':
+				sb.WriteByte('\r')
+			case 'b':
+				sb.WriteByte('\b')
+			case 'f':
+				sb.WriteByte('\f')
+			case 'v':
+				sb.WriteByte('\v')
+			case '0':
+				sb.WriteByte(0)
+			case 'u':
+				if p.pos+5 > len(p.src) {
+					return "", p.errorf("invalid unicode escape")
+				}
+				code, err := strconv.ParseUint(p.src[p.pos+1:p.pos+5], 16, 32)
+				if err != nil {
+					return "", p.errorf("invalid unicode escape")
+				}
+				p.pos += 4
+				r := rune(code)
+				if r >= 0xD800 && r < 0xDC00 && strings.HasPrefix(p.src[p.pos+1:], `\u`) && p.pos+7 <= len(p.src) {
+					if low, err := strconv.ParseUint(p.src[p.pos+3:p.pos+7], 16, 32); err == nil && low >= 0xDC00 && low < 0xE000 {
+						r = (r-0xD800)<<10 + (rune(low) - 0xDC00) + 0x10000
+						p.pos += 6
+					}
+				}
+				sb.WriteRune(r)
+			default:
+				sb.WriteByte(e)
+			}
+			p.pos++
+		default:
+			sb.WriteByte(c)
+			p.pos++
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *shellParser) parseRegex() (primitive.Regex, error) {
+	p.pos++ // opening slash
+	var sb strings.Builder
+	inClass := false
+	for {
+		if p.pos >= len(p.src) {
+			return primitive.Regex{}, p.errorf("unterminated regex")
+		}
+		c := p.src[p.pos]
+		if c == '\\' && p.pos+1 < len(p.src) {
+			if p.src[p.pos+1] != '/' {
+				sb.WriteByte(c)
+			}
+			sb.WriteByte(p.src[p.pos+1])
+			p.pos += 2
+			continue
+		}
+		p.pos++
+		if c == '/' && !inClass {
+			break
+		}
+		switch c {
+		case '[':
+			inClass = true
+		case ']':
+			inClass = false
+		}
+		sb.WriteByte(c)
+	}
+	pattern := sb.String()
+	if pattern == "(?:)" {
+		pattern = ""
+	}
+	return primitive.Regex{Pattern: pattern, Options: p.parseIdent()}, nil
+}
+
+func (p *shellParser) parseNumber() (interface{}, error) {
+	start := p.pos
+	for p.pos < len(p.src) && strings.IndexByte("+-.0123456789eE", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+	s := p.src[start:p.pos]
+	if s == "-" || s == "+" {
+		if p.parseIdent() != "Infinity" {
+			return nil, p.errorf("invalid number %q", s)
+		}
+		if s == "-" {
+			return math.Inf(-1), nil
+		}
+		return math.Inf(1), nil
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if i >= math.MinInt32 && i <= math.MaxInt32 {
+			return int32(i), nil
+		}
+		return i, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, p.errorf("invalid number %q", s)
+	}
+	return f, nil
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func (p *shellParser) parseIdent() string {
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if !isIdentStart(c) && !(c >= '0' && c <= '9') {
+			break
+		}
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+// parseIdentValue parses literals and constructors such as true, ObjectId("...").
+func (p *shellParser) parseIdentValue() (interface{}, error) {
+	ident := p.parseIdent()
+	switch ident {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	case "undefined":
+		return primitive.Undefined{}, nil
+	case "NaN":
+		return math.NaN(), nil
+	case "Infinity":
+		return math.Inf(1), nil
+	case "new":
+		p.skipSpace()
+		if ctor := p.parseIdent(); ctor != "Date" && ctor != "ISODate" {
+			return nil, p.errorf("unsupported constructor %q", ctor)
+		}
+		ident = "ISODate"
+	}
+
+	args, err := p.parseArgs()
+	if err != nil {
+		return nil, err
+	}
+	argStr := func(i int) (string, error) {
+		if i >= len(args) {
+			return "", p.errorf("%s expects %d arguments", ident, i+1)
+		}
+		switch v := args[i].(type) {
+		case string:
+			return v, nil
+		case int32, int64, float64:
+			return fmt.Sprint(v), nil
+		}
+		return "", p.errorf("invalid argument %v of %s", args[i], ident)
+	}
+
+	switch ident {
+	case "ObjectId":
+		s, err := argStr(0)
+		if err != nil {
+			return nil, err
+		}
+		id, err := primitive.ObjectIDFromHex(s)
+		if err != nil {
+			return nil, p.errorf("invalid ObjectId %q", s)
+		}
+		return id, nil
+	case "ISODate", "Date":
+		if len(args) == 0 {
+			return time.Now().UTC(), nil
+		}
+		switch ms := args[0].(type) {
+		case int32:
+			return time.UnixMilli(int64(ms)).UTC(), nil
+		case int64:
+			return time.UnixMilli(ms).UTC(), nil
+		case float64:
+			return time.UnixMilli(int64(ms)).UTC(), nil
+		}
+		s, err := argStr(0)
+		if err != nil {
+			return nil, err
+		}
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.000Z0700", "2006-01-02T15:04:05", "2006-01-02"} {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t.UTC(), nil
+			}
+		}
+		return nil, p.errorf("invalid date %q", s)
+	case "NumberInt":
+		s, err := argStr(0)
+		if err != nil {
+			return nil, err
+		}
+		i, err := strconv.ParseInt(s, 10, 32)
+		if err != nil {
+			return nil, p.errorf("invalid NumberInt %q", s)
+		}
+		return int32(i), nil
+	case "NumberLong":
+		s, err := argStr(0)
+		if err != nil {
+			return nil, err
+		}
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, p.errorf("invalid NumberLong %q", s)
+		}
+		return i, nil
+	case "NumberDecimal":
+		s, err := argStr(0)
+		if err != nil {
+			return nil, err
+		}
+		d, err := primitive.ParseDecimal128(s)
+		if err != nil {
+			return nil, p.errorf("invalid NumberDecimal %q", s)
+		}
+		return d, nil
+	case "BinData":
+		sub, err := argStr(0)
+		if err != nil {
+			return nil, err
+		}
+		data, err := argStr(1)
+		if err != nil {
+			return nil, err
+		}
+		subtype, err := strconv.ParseUint(sub, 10, 8)
+		if err != nil {
+			return nil, p.errorf("invalid BinData subtype %q", sub)
+		}
+		bin, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return nil, p.errorf("invalid BinData %q", data)
+		}
+		return primitive.Binary{Subtype: byte(subtype), Data: bin}, nil
+	case "Timestamp":
+		t, err := argStr(0)
+		if err != nil {
+			return nil, err
+		}
+		i, err := argStr(1)
+		if err != nil {
+			return nil, err
+		}
+		tv, err1 := strconv.ParseUint(t, 10, 32)
+		iv, err2 := strconv.ParseUint(i, 10, 32)
+		if err1 != nil || err2 != nil {
+			return nil, p.errorf("invalid Timestamp(%s, %s)", t, i)
+		}
+		return primitive.Timestamp{T: uint32(tv), I: uint32(iv)}, nil
+	case "MinKey":
+		return primitive.MinKey{}, nil
+	case "MaxKey":
+		return primitive.MaxKey{}, nil
+	}
+	return nil, p.errorf("unsupported identifier %q", ident)
+}
+
+// parseArgs parses arguments in parentheses.
+func (p *shellParser) parseArgs() ([]interface{}, error) {
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	args := []interface{}{}
+	for !p.consume(')') {
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+		if !p.consume(',') {
+			if err := p.expect(')'); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	return args, nil
+}
@@ -7,27 +7,35 @@ type strCond struct {
 
 // newStrCond constructs a new strCond.
 func newStrCond(key string, builderRef *Builder) *strCond {
-	return &strCond{
+	c := &strCond{
 		cond: newCond(key, builderRef),
 	}
+	c.kind = StrParam
+	return c
 }
 
-// Eq adds `$eq: val` to the strc.m
+// Negate makes the following operators to be wrapped into `$not`, eg: Str("tag").Negate().In("a", "b").
+func (strc *strCond) Negate() *strCond {
+	strc.cond.Negate()
+	return strc
+}
+
+// Eq adds `$eq: val` to the builder
 func (strc *strCond) Eq(val string) *Builder {
 	return strc.cond.Eq(val)
 }
 
-// Ne adds `$ne: val` to the strc.m
+// Ne adds `$ne: val` to the builder
 func (strc *strCond) Ne(val string) *Builder {
 	return strc.cond.Ne(val)
 }
 
-// Regex adds `$regex: exp, $options: ""` to the strc.m
+// Regex adds `$regex: exp, $options: ""` to the builder
 func (strc *strCond) Regex(exp string) *Builder {
 	return strc.cond.Regex(exp)
 }
 
-// RegexWithOpt adds `$regex: exp, $options: opt` to the strc.m
+// RegexWithOpt adds `$regex: exp, $options: opt` to the builder
 func (strc *strCond) RegexWithOpt(exp string, opt string) *Builder {
 	return strc.cond.RegexWithOpt(exp, opt)
 }
@@ -42,22 +50,42 @@ func (strc *strCond) NotLike(val string) *Builder {
 	return strc.Not(val)
 }
 
-// Not adds `$not: exp, $options: ""` to the strc.m
+// Not adds `$not: /exp/` to the builder, see NotWithOpt
 func (strc *strCond) Not(exp string) *Builder {
 	return strc.cond.Not(exp)
 }
 
-// NotWithOpt adds `$not: exp, $options: opt` to the strc.m
+// NotWithOpt adds `$not: /exp/opt` to the builder, it shares the `$not` of other negated operators of the condition
 func (strc *strCond) NotWithOpt(exp string, opt string) *Builder {
 	return strc.cond.NotWithOpt(exp, opt)
 }
 
-// In adds `$in: vals` to the strc.m
+// In adds `$in: vals` to the builder
 func (strc *strCond) In(vals ...string) *Builder {
 	return strc.cond.In(vals)
 }
 
-// In adds `$nin: vals` to the strc.m
+// In adds `$nin: vals` to the builder
 func (strc *strCond) Nin(vals ...string) *Builder {
 	return strc.cond.Nin(vals)
 }
+
+// EqParam adds `$eq: Param(name)` to the builder, see Template.
+func (strc *strCond) EqParam(name string) *Builder {
+	return strc.cond.Eq(Param(name))
+}
+
+// NeParam adds `$ne: Param(name)` to the builder, see Template.
+func (strc *strCond) NeParam(name string) *Builder {
+	return strc.cond.Ne(Param(name))
+}
+
+// InParam adds `$in: Param(name)` to the builder, the parameter should be a list of strings.
+func (strc *strCond) InParam(name string) *Builder {
+	return strc.cond.In(Param(name))
+}
+
+// NinParam adds `$nin: Param(name)` to the builder, the parameter should be a list of strings.
+func (strc *strCond) NinParam(name string) *Builder {
+	return strc.cond.Nin(Param(name))
+}
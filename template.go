@@ -0,0 +1,334 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ParamKind is the expected type of a parameter, it's decided by the condition using the parameter.
+type ParamKind int
+
+const (
+	// AnyParam accepts any value, it's used by Any and Arr conditions.
+	AnyParam ParamKind = iota
+	// NumParam accepts integers, floats and primitive.Decimal128.
+	NumParam
+	// StrParam accepts strings.
+	StrParam
+	// DateParam accepts time.Time and primitive.DateTime.
+	DateParam
+	// OidParam accepts primitive.ObjectID and valid hex strings of ObjectId.
+	OidParam
+)
+
+func (k ParamKind) String() string {
+	switch k {
+	case NumParam:
+		return "number"
+	case StrParam:
+		return "string"
+	case DateParam:
+		return "date"
+	case OidParam:
+		return "ObjectId"
+	}
+	return "any"
+}
+
+// Placeholder is a parameter of a Template, see Param.
+type Placeholder struct {
+	// Name is the name of the parameter.
+	Name string
+	// Kind is the expected type of the parameter.
+	Kind ParamKind
+}
+
+// Param returns a placeholder of the parameter name, which can be used as a value of conditions,
+// eg: Num("age").Gte(Param("minAge")). The value is given later by Template.Bind.
+//
+// Conditions whose values are not interface{} have their own methods for parameters, eg: Date("created").GteParam("since").
+// Operators taking a list, such as `$in`, expect a slice of the kind.
+// Placeholders are only replaced by templates, Build fails the conditions using them.
+func Param(name string) Placeholder {
+	return Placeholder{Name: name}
+}
+
+// param sets the kind of the condition to val if it's a placeholder.
+func (baseCond *cond) param(val interface{}) interface{} {
+	if p, ok := val.(Placeholder); ok {
+		p.Kind = baseCond.kind
+		return p
+	}
+	return val
+}
+
+// Template is a filter with placeholders, it's safe for concurrent use by multiple goroutines.
+//
+// A template is built once with Builder.Template, and bound to values per use with Bind.
+type Template struct {
+	root   Node
+	params map[string]ParamKind
+}
+
+// Template returns a template of the builder, values of placeholders are given later by Template.Bind.
+//
+// It returns an error if the builder has recorded errors,
+// or a parameter is used by conditions of different kinds.
+func (b *Builder) Template() (*Template, error) {
+	if err := errors.Join(b.errs...); err != nil {
+		return nil, err
+	}
+
+	tpl := &Template{root: b.Clone().AST(), params: map[string]ParamKind{}}
+	var err error
+	Walk(VisitorFunc(func(n Node) bool {
+		c, ok := n.(*Compare)
+		if !ok || err != nil {
+			return err == nil
+		}
+		for _, p := range placeholders(c.Value) {
+			kind, ok := tpl.params[p.Name]
+			switch {
+			case !ok || kind == AnyParam:
+				tpl.params[p.Name] = p.Kind
+			case p.Kind != AnyParam && p.Kind != kind:
+				err = fmt.Errorf("filterBuilder: parameter %q is used as both %s and %s", p.Name, kind, p.Kind)
+				return false
+			}
+		}
+		return true
+	}), tpl.root)
+	if err != nil {
+		return nil, err
+	}
+	return tpl, nil
+}
+
+// stripUnbound returns the tree of root without conditions using placeholders,
+// and errors of the removed conditions.
+func stripUnbound(root Node) (Node, []error) {
+	var errs []error
+	root = Rewrite(root, RewriterFunc(func(n Node) Node {
+		switch n := n.(type) {
+		case *Compare:
+			ps := placeholders(n.Value)
+			for _, p := range ps {
+				errs = append(errs, &CondError{Key: n.Field, Op: n.Op, Cause: fmt.Errorf("unbound parameter %q, use Template to bind it", p.Name)})
+			}
+			if len(ps) != 0 {
+				return nil
+			}
+		case *Not:
+			if len(n.Ops) == 0 {
+				return nil
+			}
+		}
+		return n
+	}))
+	return root, errs
+}
+
+// placeholders returns placeholders in val, val can be a placeholder, a slice of values,
+// or a document such as the rendered predicate of a negated `$elemMatch`.
+func placeholders(val interface{}) []Placeholder {
+	switch v := val.(type) {
+	case Placeholder:
+		return []Placeholder{v}
+	case bson.D:
+		var res []Placeholder
+		for _, e := range v {
+			res = append(res, placeholders(e.Value)...)
+		}
+		return res
+	case bson.M:
+		var res []Placeholder
+		for _, e := range v {
+			res = append(res, placeholders(e)...)
+		}
+		return res
+	}
+	if !hasPlaceholder(val) {
+		return nil
+	}
+	v := reflect.ValueOf(val)
+	var res []Placeholder
+	for i := 0; i < v.Len(); i++ {
+		res = append(res, placeholders(v.Index(i).Interface())...)
+	}
+	return res
+}
+
+// Params returns names of all parameters of the template and their kinds.
+func (tpl *Template) Params() map[string]ParamKind {
+	res := make(map[string]ParamKind, len(tpl.params))
+	for name, kind := range tpl.params {
+		res[name] = kind
+	}
+	return res
+}
+
+// Bind replaces placeholders with params and returns the final filter as bson.M.
+//
+// Each parameter must be given and is checked against its kind,
+// errors of failed parameters are joined as one, each of them is a *CondError.
+func (tpl *Template) Bind(params map[string]any) (bson.M, error) {
+	d, err := tpl.BindD(params)
+	if err != nil {
+		return nil, err
+	}
+	return toM(d), nil
+}
+
+// BindD is like Bind but returns the final filter as bson.D.
+func (tpl *Template) BindD(params map[string]any) (bson.D, error) {
+	var errs []error
+	for _, name := range sortedKeys(tpl.params) {
+		if _, ok := params[name]; !ok {
+			errs = append(errs, fmt.Errorf("filterBuilder: missing parameter %q", name))
+		}
+	}
+	for _, name := range sortedKeys(params) {
+		if _, ok := tpl.params[name]; !ok {
+			errs = append(errs, fmt.Errorf("filterBuilder: unknown parameter %q", name))
+		}
+	}
+	if len(errs) != 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	root := Rewrite(tpl.root, RewriterFunc(func(n Node) Node {
+		c, ok := n.(*Compare)
+		if !ok || len(placeholders(c.Value)) == 0 {
+			return n
+		}
+		val, err := bindValue(c.Op, c.Value, params)
+		if err != nil {
+			errs = append(errs, &CondError{Key: c.Field, Op: c.Op, Cause: err})
+			return n
+		}
+		return &Compare{Field: c.Field, Op: c.Op, Value: val}
+	}))
+	if len(errs) != 0 {
+		return nil, errors.Join(errs...)
+	}
+	return render(root), nil
+}
+
+// sortedKeys returns keys of m in order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// bindValue replaces placeholders in val of op with params, placeholders in nested documents are bound with their own operators.
+func bindValue(op string, val interface{}, params map[string]any) (interface{}, error) {
+	switch v := val.(type) {
+	case Placeholder:
+		if op == _in || op == _nin || op == _all {
+			return bindList(v, params[v.Name])
+		}
+		return bindScalar(v, params[v.Name])
+	case bson.D:
+		res := make(bson.D, 0, len(v))
+		for _, e := range v {
+			bound, err := bindValue(e.Key, e.Value, params)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, bson.E{Key: e.Key, Value: bound})
+		}
+		return res, nil
+	case bson.M:
+		res := make(bson.M, len(v))
+		for k, e := range v {
+			bound, err := bindValue(k, e, params)
+			if err != nil {
+				return nil, err
+			}
+			res[k] = bound
+		}
+		return res, nil
+	}
+	if !hasPlaceholder(val) {
+		return val, nil
+	}
+
+	// elements of a list are single values
+	v := reflect.ValueOf(val)
+	res := make([]interface{}, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		e, err := bindValue("", v.Index(i).Interface(), params)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+// bindList checks each element of the list val against the kind of p.
+func bindList(p Placeholder, val interface{}) (interface{}, error) {
+	v := reflect.ValueOf(val)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("parameter %q expects a list of %s, got %T", p.Name, p.Kind, val)
+	}
+	res := make([]interface{}, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		e, err := bindScalar(p, v.Index(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+// bindScalar checks val against the kind of p, hex strings of OidParam are converted to ObjectId.
+func bindScalar(p Placeholder, val interface{}) (interface{}, error) {
+	ok := false
+	switch p.Kind {
+	case AnyParam:
+		ok = true
+	case NumParam:
+		switch reflect.ValueOf(val).Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			ok = true
+		default:
+			_, ok = val.(primitive.Decimal128)
+		}
+	case StrParam:
+		_, ok = val.(string)
+	case DateParam:
+		switch val.(type) {
+		case time.Time, primitive.DateTime:
+			ok = true
+		}
+	case OidParam:
+		switch v := val.(type) {
+		case primitive.ObjectID:
+			ok = true
+		case string:
+			id, err := primitive.ObjectIDFromHex(v)
+			if err != nil {
+				return nil, fmt.Errorf("parameter %q: %v", p.Name, err)
+			}
+			return id, nil
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("parameter %q expects %s, got %T", p.Name, p.Kind, val)
+	}
+	return val, nil
+}
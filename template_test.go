@@ -0,0 +1,155 @@
+package builder_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	builder "github.com/JsyTech/mongo-filter-builder"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestTemplate(t *testing.T) {
+	tpl, err := builder.New().
+		Num("age").Gte(builder.Param("minAge")).
+		Str("name").InParam("names").
+		Date("created").Negate().LtParam("since").
+		Oid().EqParam("id").
+		Or().
+		Arr("tags").ContainsAll("x", builder.Param("tag")).
+		Num("age").Gte(builder.Param("minAge")).
+		Template()
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]builder.ParamKind{
+		"minAge": builder.NumParam,
+		"names":  builder.StrParam,
+		"since":  builder.DateParam,
+		"id":     builder.OidParam,
+		"tag":    builder.AnyParam,
+	}, tpl.Params())
+
+	day := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	id := primitive.NewObjectID()
+	filter, err := tpl.Bind(map[string]any{
+		"minAge": 18,
+		"names":  []string{"a", "b"},
+		"since":  day,
+		"id":     id.Hex(),
+		"tag":    "y",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, bson.M{"$or": []bson.M{
+		{
+			"age":     bson.M{"$gte": 18},
+			"name":    bson.M{"$in": []interface{}{"a", "b"}},
+			"created": bson.M{"$not": bson.M{"$lt": day}},
+			"_id":     bson.M{"$eq": id},
+		},
+		{
+			"tags": bson.M{"$all": []interface{}{"x", "y"}},
+			"age":  bson.M{"$gte": 18},
+		},
+	}}, filter)
+
+	// the template can be bound again with other values
+	filter, err = tpl.Bind(map[string]any{
+		"minAge": 20.5,
+		"names":  []string{},
+		"since":  primitive.NewDateTimeFromTime(day),
+		"id":     id,
+		"tag":    1,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, bson.M{"$gte": 20.5}, filter["$or"].([]bson.M)[1]["age"])
+}
+
+func TestTemplate_Error(t *testing.T) {
+	tpl, err := builder.New().
+		Num("age").Gte(builder.Param("age")).
+		Date("created").BetweenParam("from", "to").
+		Oid().EqParam("id").
+		Template()
+	assert.Nil(t, err)
+
+	_, err = tpl.Bind(map[string]any{"age": 1, "from": time.Now(), "id": "x", "other": 1})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), `missing parameter "to"`)
+	assert.Contains(t, err.Error(), `unknown parameter "other"`)
+
+	_, err = tpl.Bind(map[string]any{"age": "1", "from": "2021-01-01", "to": time.Now(), "id": "x"})
+	var condErr *builder.CondError
+	assert.True(t, errors.As(err, &condErr))
+	assert.Contains(t, err.Error(), `parameter "age" expects number, got string`)
+	assert.Contains(t, err.Error(), `parameter "from" expects date, got string`)
+	assert.Contains(t, err.Error(), `parameter "id"`)
+
+	_, err = builder.New().Num("a").Eq(builder.Param("p")).Str("b").EqParam("p").Template()
+	assert.NotNil(t, err)
+
+	_, err = builder.NewSafe().Oid().Eq("x").Template()
+	assert.NotNil(t, err)
+
+	tpl, err = builder.New().Str("name").InParam("names").Template()
+	assert.Nil(t, err)
+	_, err = tpl.Bind(map[string]any{"names": "a"})
+	assert.NotNil(t, err)
+	_, err = tpl.Bind(map[string]any{"names": []interface{}{"a", 1}})
+	assert.NotNil(t, err)
+}
+
+func TestTemplate_NestedParam(t *testing.T) {
+	b := builder.New().
+		Arr("items").Negate().ElemMatch(func(e *builder.Builder) { e.Num("qty").Gt(builder.Param("q")) }).
+		Num("age").Gte(builder.Param("minAge"))
+	tpl, err := b.Template()
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]builder.ParamKind{"q": builder.NumParam, "minAge": builder.NumParam}, tpl.Params())
+
+	filter, err := tpl.Bind(map[string]any{"q": 5, "minAge": 18})
+	assert.Nil(t, err)
+	assert.Equal(t, bson.M{
+		"items": bson.M{"$not": bson.M{"$elemMatch": bson.M{"qty": bson.M{"$gt": 5}}}},
+		"age":   bson.M{"$gte": 18},
+	}, filter)
+
+	_, err = tpl.Bind(map[string]any{"q": "5", "minAge": 18})
+	assert.Contains(t, err.Error(), `parameter "q" expects number, got string`)
+
+	// unbound nested placeholders fail the condition as well
+	assert.Panics(t, func() { b.Build() })
+	f, err := builder.NewSafe().
+		Arr("items").Negate().ElemMatch(func(e *builder.Builder) { e.Num("qty").Gt(builder.Param("q")) }).
+		Str("name").Eq("a").
+		BuildE()
+	assert.Equal(t, `filterBuilder: failed to build $elemMatch on key "items": unbound parameter "q", use Template to bind it`, err.Error())
+	assert.Nil(t, f)
+}
+
+func TestBuilder_BuildUnbound(t *testing.T) {
+	assert.Panics(t, func() { builder.New().Num("age").Gte(builder.Param("age")).Build() })
+	assert.Panics(t, func() { builder.New().Date("created").Negate().LtParam("since").BuildD() })
+
+	b := builder.NewSafe().Num("age").Gte(builder.Param("age")).Str("name").Eq("a")
+	_, err := b.BuildE()
+	var condErr *builder.CondError
+	assert.True(t, errors.As(err, &condErr))
+	assert.Equal(t, "age", condErr.Key)
+	assert.Equal(t, "$gte", condErr.Op)
+	assert.Equal(t, `filterBuilder: failed to build $gte on key "age": unbound parameter "age", use Template to bind it`, err.Error())
+
+	// the failed condition is skipped, and the builder is not changed by building it
+	assert.Equal(t, bson.M{"name": bson.M{"$eq": "a"}}, b.Build())
+	assert.Empty(t, b.Errors())
+	_, err = b.BuildE()
+	assert.NotNil(t, err)
+	assert.Equal(t, bson.M{}, builder.NewSafe().Num("age").Negate().Gt(builder.Param("age")).Build())
+
+	// templates of the builder are not affected, even after it's built
+	tpl, err := b.Template()
+	assert.Nil(t, err)
+	filter, err := tpl.Bind(map[string]any{"age": 1})
+	assert.Nil(t, err)
+	assert.Equal(t, bson.M{"age": bson.M{"$gte": 1}, "name": bson.M{"$eq": "a"}}, filter)
+}
@@ -0,0 +1,110 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+)
+
+// TextOption sets an optional field of `$text`.
+type TextOption func(t *Text)
+
+// TextLanguage sets `$language` of `$text`.
+func TextLanguage(lang string) TextOption {
+	return func(t *Text) {
+		t.Language = lang
+	}
+}
+
+// TextCaseSensitive sets `$caseSensitive` of `$text`.
+func TextCaseSensitive(sensitive bool) TextOption {
+	return func(t *Text) {
+		t.CaseSensitive = &sensitive
+	}
+}
+
+// TextDiacriticSensitive sets `$diacriticSensitive` of `$text`.
+func TextDiacriticSensitive(sensitive bool) TextOption {
+	return func(t *Text) {
+		t.DiacriticSensitive = &sensitive
+	}
+}
+
+// Text adds `$text: {$search: search, ...}` to the top level of the current condition,
+// so it's placed at the top level of the current `$or` branch if Or() is used.
+//
+// MongoDB allows at most one `$text` in a filter and doesn't allow it in `$nor`, `$not` or `$elemMatch`,
+// the condition fails once the filter would break these restrictions.
+func (b *Builder) Text(search string, opts ...TextOption) *Builder {
+	t := &Text{Search: search}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return b.add("", _text, t)
+}
+
+var errTextOnce = errors.New("$text can only be used once in a filter")
+
+// checkText checks whether `$text` would be used illegally once candidate is added to the builder
+// in place of old, only candidate is walked since the `$text` of the builder are counted by b.texts.
+// It returns the count of `$text` after the change, the failure will be reported with key and op.
+func (b *Builder) checkText(key, op string, candidate, old Node) (int, bool) {
+	count, err := checkText(candidate)
+	if count += b.texts - countText(old); err == nil && count > 1 {
+		err = errTextOnce
+	}
+	if err != nil {
+		b.fail(key, op, err)
+		return b.texts, false
+	}
+	return count, true
+}
+
+// countText counts the `$text` in the nodes.
+func countText(nodes ...Node) int {
+	count := 0
+	for _, n := range nodes {
+		c, _ := checkText(n)
+		count += c
+	}
+	return count
+}
+
+// checkText walks n and counts the `$text` in it, the first illegal usage is reported.
+func checkText(n Node) (int, error) {
+	count := 0
+	var err error
+	var walk func(n Node, illegalIn string)
+	walk = func(n Node, illegalIn string) {
+		switch n := n.(type) {
+		case *Text:
+			if illegalIn != "" {
+				if err == nil {
+					err = fmt.Errorf("$text can't be used in %s", illegalIn)
+				}
+				return
+			}
+			if count++; count > 1 && err == nil {
+				err = errTextOnce
+			}
+		case *And:
+			for _, c := range n.Children {
+				walk(c, illegalIn)
+			}
+		case *Or:
+			for _, c := range n.Children {
+				walk(c, illegalIn)
+			}
+		case *Nor:
+			if illegalIn == "" {
+				illegalIn = _nor
+			}
+			for _, c := range n.Children {
+				walk(c, illegalIn)
+			}
+		case *ElemMatch:
+			walk(n.Filter, _elemMatch)
+		}
+	}
+	walk(n, "")
+	return count, err
+}
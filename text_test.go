@@ -0,0 +1,71 @@
+package builder_test
+
+import (
+	"testing"
+
+	builder "github.com/JsyTech/mongo-filter-builder"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestBuilder_Text(t *testing.T) {
+	b := builder.New().Text("coffee").Build()
+	c := bson.M{"$text": bson.M{"$search": "coffee"}}
+	assert.Equal(t, c, b)
+
+	b = builder.New().
+		Text("café", builder.TextLanguage("fr"), builder.TextCaseSensitive(true), builder.TextDiacriticSensitive(false)).
+		Num("price").Lt(10).
+		Build()
+	c = bson.M{
+		"$text": bson.M{
+			"$search":             "café",
+			"$language":           "fr",
+			"$caseSensitive":      true,
+			"$diacriticSensitive": false,
+		},
+		"price": bson.M{"$lt": 10},
+	}
+	assert.Equal(t, c, b)
+
+	// $text is placed at the top level of the current $or branch
+	b = builder.New().Str("name").Eq("a").Or().Text("coffee").Build()
+	c = bson.M{"$or": []bson.M{
+		{"name": bson.M{"$eq": "a"}},
+		{"$text": bson.M{"$search": "coffee"}},
+	}}
+	assert.Equal(t, c, b)
+
+	assert.Panics(t, func() { builder.New().Text("a").Text("b") })
+	assert.Panics(t, func() { builder.New().Text("a").Or().Text("b") })
+	assert.Panics(t, func() {
+		builder.New().Text("a").OrGroup(func(g *builder.Builder) { g.Text("b") })
+	})
+	assert.Panics(t, func() {
+		builder.New().NorGroup(func(g *builder.Builder) { g.Text("a") })
+	})
+	assert.Panics(t, func() {
+		builder.New().Arr("items").ElemMatch(func(e *builder.Builder) { e.Text("a") })
+	})
+	assert.NotPanics(t, func() {
+		builder.New().OrGroup(func(g *builder.Builder) { g.Text("a").Or().Str("name").Eq("a") })
+	})
+
+	// the count of $text is kept by the builders derived from or rebuilt from a builder
+	text := builder.New().Text("a").Str("name").Eq("a")
+	assert.Panics(t, func() { text.Clone().Text("b") })
+	assert.Panics(t, func() { text.Freeze().Builder().Text("b") })
+	assert.Panics(t, func() { builder.FromAST(text.AST()).Text("b") })
+	assert.Panics(t, func() { text.Clone().Optimize().Text("b") })
+	assert.Panics(t, func() { text.Clone().RemoveCond("name").Text("b") })
+	assert.NotPanics(t, func() { text.Clone().Flush().Text("b") })
+	assert.NotPanics(t, func() { builder.New().Text("a").Num("age").Gt(1).Num("age").Gt(2).Or().Str("name").Eq("a") })
+
+	// a failed $text is not counted
+	safe := builder.NewSafe().Text("a").Text("b")
+	assert.Len(t, safe.Errors(), 1)
+	assert.Len(t, safe.Text("c").Errors(), 2)
+	safe = builder.NewSafe().NorGroup(func(g *builder.Builder) { g.Text("a") })
+	assert.NotEmpty(t, safe.Errors())
+	assert.NotPanics(t, func() { safe.Strict(true).Text("b") })
+}